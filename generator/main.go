@@ -2,33 +2,153 @@ package main
 
 import (
 	"bufio"
+	"encoding/binary"
+	"flag"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 )
 
+// textOptions describe the layout of points.txt.
+type textOptions struct {
+	crlf       bool
+	bom        bool
+	spaces     bool
+	header     bool
+	delimiter  string // The delimiter itself, e.g. "\t"
+	decimal    string // The decimal separator itself, e.g. ","
+	precision  int
+	scientific bool
+}
+
+// format is a delimiter and decimal separator pair the parser can read. The
+// table mirrors formats in golang/formats.go, which main_test.go checks by having
+// the parser sum a file of every pair, so that every file generated can be parsed.
+type format struct {
+	delimiter, decimal byte
+}
+
+var formats = []format{
+	{',', '.'},
+	{'\t', '.'},
+	{';', '.'},
+	{'|', '.'},
+	{';', ','}, // 1,50;-2,25
+}
+
+// findFormat looks a format up by its delimiter and decimal separator names
+// the way the parser's findFormat does.
+func findFormat(delimiter, decimal string) (format, error) {
+	delimiters := map[string]byte{"comma": ',', "tab": '\t', "semicolon": ';', "pipe": '|'}
+	decimals := map[string]byte{"dot": '.', "comma": ','}
+	delim, ok := delimiters[delimiter]
+	if !ok {
+		return format{}, fmt.Errorf("unknown delimiter %q (want comma, tab, semicolon or pipe)", delimiter)
+	}
+	point, ok := decimals[decimal]
+	if !ok {
+		return format{}, fmt.Errorf("unknown decimal separator %q (want dot or comma)", decimal)
+	}
+	for _, f := range formats {
+		if f.delimiter == delim && f.decimal == point {
+			return f, nil
+		}
+	}
+	return format{}, fmt.Errorf("the parser cannot read %s delimited fields with %s decimals", delimiter, decimal)
+}
+
 func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	var numOfLines int
-	fmt.Print("how many points to generate? (100000000 ~=  1.2G): ")
-	fmt.Scanln(&numOfLines)
+	flag.IntVar(&numOfLines, "lines", 0, "number of points to generate (asked interactively when 0)")
+	crlf := flag.Bool("crlf", false, "end lines with \\r\\n instead of \\n")
+	bom := flag.Bool("bom", false, "start points.txt with a UTF-8 byte order mark")
+	spaces := flag.Bool("spaces", false, "put a space after each comma")
+	header := flag.Bool("header", false, "start points.txt with an \"x,y\" header line")
+	delimiter := flag.String("delimiter", "comma", "field delimiter: comma, tab, semicolon or pipe")
+	decimal := flag.String("decimal", "dot", "decimal separator: dot or comma (comma only with -delimiter semicolon)")
+	precision := flag.Int("precision", 2, "number of decimals written per point")
+	scientific := flag.Bool("scientific", false, "write points in scientific notation, e.g. -1.23e+01")
+	binaryOut := flag.Bool("binary", false, "write points.bin in the parser's binary format instead of points.txt")
+	flag.Parse()
+
+	f, err := findFormat(*delimiter, *decimal)
+	if err != nil {
+		return err
+	}
+	if numOfLines < 0 {
+		return fmt.Errorf("-lines must not be negative")
+	}
+	if *precision < 0 {
+		return fmt.Errorf("-precision must not be negative")
+	}
+	if *binaryOut && (*precision != 2 || *scientific) {
+		return fmt.Errorf("-binary stores hundredths, it needs -precision 2 and no -scientific")
+	}
 
 	if numOfLines == 0 {
+		fmt.Print("how many points to generate? (100000000 ~=  1.2G): ")
+		fmt.Scanln(&numOfLines)
+	}
+
+	if numOfLines <= 0 {
 		numOfLines = 100000000
 	}
 
-	pf, err := os.Create("points.txt")
+	// The parser looks for the sums of points.bin in their own file, so that
+	// generating one format leaves the sums of the other in place.
+	outName, verifyName := "points.txt", "points-verify.txt"
+	if *binaryOut {
+		outName, verifyName = "points.bin", "points-verify.bin.txt"
+	}
+	pf, err := os.Create(outName)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer pf.Close()
 
-	pfv, err := os.Create("points-verify.txt")
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	fmt.Printf("Generating %d lines\n", numOfLines)
+	var s1, s2 float64
+	if *binaryOut {
+		s1, s2, err = generateBinary(pf, rng, numOfLines)
+	} else {
+		s1, s2, err = generateText(pf, rng, numOfLines, textOptions{
+			crlf:       *crlf,
+			bom:        *bom,
+			spaces:     *spaces,
+			header:     *header,
+			delimiter:  string(f.delimiter),
+			decimal:    string(f.decimal),
+			precision:  *precision,
+			scientific: *scientific,
+		})
+	}
+	if err == nil {
+		err = pf.Close()
+	}
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer pfv.Close()
+
+	fmt.Println(fmt.Sprintf("%.6f", s1), fmt.Sprintf("%.6f", s2), numOfLines)
+	return os.WriteFile(verifyName, []byte(fmt.Sprintf("%.2f,%.2f,%d\n", s1, s2, numOfLines)), 0o644)
+}
+
+// generateText writes numOfLines random points to w as text and returns the
+// sums of the values as written, rounded to hundredths.
+func generateText(w io.Writer, rng *rand.Rand, numOfLines int, o textOptions) (float64, float64, error) {
+	pointsBuf := bufio.NewWriter(w)
 
 	min := -99.99
 	max := 99.99
@@ -36,31 +156,91 @@ func main() {
 	sum1 := 0.0
 	sum2 := 0.0
 
-	pointsBuf := bufio.NewWriter(pf)
+	sep := o.delimiter
+	if o.spaces {
+		sep += " "
+	}
+	eol := "\n"
+	if o.crlf {
+		eol = "\r\n"
+	}
+	if o.bom {
+		pointsBuf.WriteString("\xEF\xBB\xBF")
+	}
+	if o.header {
+		pointsBuf.WriteString("x" + sep + "y" + eol)
+	}
+
+	verb := "%.*f"
+	if o.scientific {
+		verb = "%.*e"
+	}
 
-	fmt.Printf("Generating %d lines\n", numOfLines)
 	for i := 0; i < numOfLines; i++ {
-		r1 := min + rand.Float64()*(max-min)
+		r1 := min + rng.Float64()*(max-min)
 		// r2 := min + rand.Float64()*(max-min)
-		r2 := r1 + rand.Float64()*(max-r1)
-		sr1 := fmt.Sprintf("%.2f", r1)
+		r2 := r1 + rng.Float64()*(max-r1)
+		sr1 := fmt.Sprintf(verb, o.precision, r1)
 		r1, _ = strconv.ParseFloat(sr1, 8)
-		sr2 := fmt.Sprintf("%.2f", r2)
+		sr2 := fmt.Sprintf(verb, o.precision, r2)
 		r2, _ = strconv.ParseFloat(sr2, 8)
 		sum1 = sum1 + r1
 		sum2 = sum2 + r2
-		pointsBuf.WriteString(fmt.Sprintf("%.2f,%.2f\n", r1, r2))
+		pointsBuf.WriteString(strings.Replace(sr1, ".", o.decimal, 1) + sep + strings.Replace(sr2, ".", o.decimal, 1) + eol)
 	}
 
 	if err := pointsBuf.Flush(); err != nil {
-		panic(err)
+		return 0, 0, err
 	}
 
 	// fmt.Println(fmt.Sprintf("%.10f", sum1), fmt.Sprintf("%.10f", sum2), numOfLines)
 	s1 := math.Round(sum1*100) / 100
 	s2 := math.Round(sum2*100) / 100
-	fmt.Println(fmt.Sprintf("%.6f", s1), fmt.Sprintf("%.6f", s2), numOfLines)
+	return s1, s2, nil
+}
+
+// generateBinary writes the same points as generateText to w in the parser's
+// binary format: a 16 byte little-endian header ("PTSB", uint16 version 1,
+// uint16 column count, uint64 row count), then every x and then every y as
+// int16 hundredths. The number of rows is known up front, so the y column is
+// written through a second writer starting right after the x column.
+func generateBinary(w io.WriterAt, rng *rand.Rand, numOfLines int) (float64, float64, error) {
+	const headerSize = 16
+	xs := bufio.NewWriter(io.NewOffsetWriter(w, 0))
+	ys := bufio.NewWriter(io.NewOffsetWriter(w, headerSize+2*int64(numOfLines)))
+
+	header := make([]byte, headerSize)
+	copy(header, "PTSB")
+	binary.LittleEndian.PutUint16(header[4:], 1)
+	binary.LittleEndian.PutUint16(header[6:], 2)
+	binary.LittleEndian.PutUint64(header[8:], uint64(numOfLines))
+	xs.Write(header)
+
+	min := -99.99
+	max := 99.99
+
+	var sum1, sum2 int64
+	var value [2]byte
+
+	for i := 0; i < numOfLines; i++ {
+		r1 := min + rng.Float64()*(max-min)
+		r2 := r1 + rng.Float64()*(max-r1)
+		h1 := int16(math.Round(r1 * 100))
+		h2 := int16(math.Round(r2 * 100))
+		sum1 += int64(h1)
+		sum2 += int64(h2)
+		binary.LittleEndian.PutUint16(value[:], uint16(h1))
+		xs.Write(value[:])
+		binary.LittleEndian.PutUint16(value[:], uint16(h2))
+		ys.Write(value[:])
+	}
+
+	if err := xs.Flush(); err != nil {
+		return 0, 0, err
+	}
+	if err := ys.Flush(); err != nil {
+		return 0, 0, err
+	}
 
-	// pfv.WriteString(fmt.Sprintf("%s,%s,%d\n", s1[:len(s1)-8], s2[:len(s2)-8], numOfLines))
-	pfv.WriteString(fmt.Sprintf("%.2f,%.2f,%d\n", s1, s2, numOfLines))
+	return float64(sum1) / 100, float64(sum2) / 100, nil
 }
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readBack checks that every line of points.txt has the layout the options
+// ask for and returns the values of the lines.
+func readBack(t *testing.T, data []byte, o textOptions) (xs, ys []float64) {
+	t.Helper()
+	if o.bom {
+		if !bytes.HasPrefix(data, []byte("\xEF\xBB\xBF")) {
+			t.Fatal("no byte order mark")
+		}
+		data = data[3:]
+	}
+	eol := "\n"
+	if o.crlf {
+		eol = "\r\n"
+	}
+	if !strings.HasSuffix(string(data), eol) {
+		t.Fatalf("last line does not end with %q", eol)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), eol), eol)
+	if o.header {
+		if want := "x" + o.delimiter + "y"; strings.ReplaceAll(lines[0], " ", "") != want {
+			t.Fatalf("header %q, want %q", lines[0], want)
+		}
+		lines = lines[1:]
+	}
+
+	number := `-?\d+` + regexp.QuoteMeta(o.decimal) + `\d{` + strconv.Itoa(o.precision) + `}`
+	if o.precision == 0 {
+		number = `-?\d+`
+	}
+	if o.scientific {
+		number += `e[+-]\d{2}`
+	}
+	sep := regexp.QuoteMeta(o.delimiter)
+	if o.spaces {
+		sep += " "
+	}
+	lineRe := regexp.MustCompile(`^(` + number + `)` + sep + `(` + number + `)$`)
+
+	for _, line := range lines {
+		m := lineRe.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("line %q does not match %s", line, lineRe)
+		}
+		x, errX := strconv.ParseFloat(strings.Replace(m[1], o.decimal, ".", 1), 64)
+		y, errY := strconv.ParseFloat(strings.Replace(m[2], o.decimal, ".", 1), 64)
+		if errX != nil || errY != nil {
+			t.Fatalf("line %q: %v %v", line, errX, errY)
+		}
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	return xs, ys
+}
+
+// checkPoints checks that the points are in range, which is ±100 once
+// rounded to fewer decimals, and that the returned sums are those of the
+// points.
+func checkPoints(t *testing.T, xs, ys []float64, lines int, sum1, sum2 float64) {
+	t.Helper()
+	if len(xs) != lines {
+		t.Fatalf("read %d points, want %d", len(xs), lines)
+	}
+	var s1, s2 float64
+	for i := range xs {
+		if xs[i] < -100 || ys[i] > 100 || ys[i] < xs[i] {
+			t.Errorf("point %d = %v, %v, want -100 <= x <= y <= 100", i, xs[i], ys[i])
+		}
+		s1 += xs[i]
+		s2 += ys[i]
+	}
+	if math.Abs(s1-sum1) > 0.005 || math.Abs(s2-sum2) > 0.005 {
+		t.Errorf("sums of the points %.2f, %.2f, returned %.2f, %.2f", s1, s2, sum1, sum2)
+	}
+}
+
+func TestGenerateText(t *testing.T) {
+	plain := textOptions{delimiter: ",", decimal: ".", precision: 2}
+	tests := []struct {
+		name   string
+		modify func(o *textOptions)
+	}{
+		{"plain", func(o *textOptions) {}},
+		{"crlf", func(o *textOptions) { o.crlf = true }},
+		{"bom", func(o *textOptions) { o.bom = true }},
+		{"spaces", func(o *textOptions) { o.spaces = true }},
+		{"header", func(o *textOptions) { o.header = true }},
+		{"everything", func(o *textOptions) { o.crlf, o.bom, o.spaces, o.header = true, true, true, true }},
+		{"tab", func(o *textOptions) { o.delimiter = "\t" }},
+		{"semicolon", func(o *textOptions) { o.delimiter = ";" }},
+		{"pipe", func(o *textOptions) { o.delimiter = "|" }},
+		{"european", func(o *textOptions) { o.delimiter, o.decimal = ";", "," }},
+		{"precision 0", func(o *textOptions) { o.precision = 0 }},
+		{"precision 5", func(o *textOptions) { o.precision = 5 }},
+		{"scientific", func(o *textOptions) { o.scientific = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := plain
+			tt.modify(&o)
+			var b bytes.Buffer
+			sum1, sum2, err := generateText(&b, rand.New(rand.NewSource(1)), 1000, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			xs, ys := readBack(t, b.Bytes(), o)
+			checkPoints(t, xs, ys, 1000, sum1, sum2)
+		})
+	}
+}
+
+func TestGenerateBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.bin")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	const lines = 1000
+	sum1, sum2, err := generateBinary(file, rand.New(rand.NewSource(1)), lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 16+4*lines {
+		t.Fatalf("file is %d bytes, want %d", len(data), 16+4*lines)
+	}
+	if string(data[:4]) != "PTSB" || binary.LittleEndian.Uint16(data[4:]) != 1 ||
+		binary.LittleEndian.Uint16(data[6:]) != 2 || binary.LittleEndian.Uint64(data[8:]) != lines {
+		t.Fatalf("bad header % x", data[:16])
+	}
+	var xs, ys []float64
+	for i := 0; i < lines; i++ {
+		xs = append(xs, float64(int16(binary.LittleEndian.Uint16(data[16+2*i:])))/100)
+		ys = append(ys, float64(int16(binary.LittleEndian.Uint16(data[16+2*lines+2*i:])))/100)
+	}
+	checkPoints(t, xs, ys, lines, sum1, sum2)
+}
+
+// TestFormatsMatchParser builds the parser and has it sum a file of every
+// delimiter and decimal separator pair: the pairs the generator writes must
+// be read back with the same sums, and the parser must reject the others.
+func TestFormatsMatchParser(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not in PATH")
+	}
+	dir := t.TempDir()
+	parserBin := filepath.Join(dir, "parser")
+	build := exec.Command("go", "build", "-o", parserBin, ".")
+	build.Dir = filepath.Join("..", "golang")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building the parser: %v\n%s", err, out)
+	}
+
+	const lines = 1000
+	sumRe := regexp.MustCompile(`(?m)^lines: (\d+)\nx: sum (\S+) .*\ny: sum (\S+) `)
+	for _, delimiter := range []string{"comma", "tab", "semicolon", "pipe"} {
+		for _, decimal := range []string{"dot", "comma"} {
+			path := filepath.Join(dir, delimiter+"-"+decimal+".txt")
+			f, genErr := findFormat(delimiter, decimal)
+			o := textOptions{delimiter: string(f.delimiter), decimal: string(f.decimal), precision: 2}
+			if genErr != nil {
+				o.delimiter, o.decimal = ",", "." // Any file will do; the parser must refuse the flags
+			}
+			var b bytes.Buffer
+			sum1, sum2, err := generateText(&b, rand.New(rand.NewSource(1)), lines, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := exec.Command(parserBin, "sum", "-file", path, "-delimiter", delimiter, "-decimal", decimal).CombinedOutput()
+			if genErr != nil {
+				if err == nil {
+					t.Errorf("%s/%s: the generator refuses the pair but the parser read it:\n%s", delimiter, decimal, out)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s/%s: the parser failed: %v\n%s", delimiter, decimal, err, out)
+				continue
+			}
+			m := sumRe.FindStringSubmatch(string(out))
+			if m == nil {
+				t.Fatalf("%s/%s: unexpected parser output:\n%s", delimiter, decimal, out)
+			}
+			n, _ := strconv.Atoi(m[1])
+			x, errX := strconv.ParseFloat(m[2], 64)
+			y, errY := strconv.ParseFloat(m[3], 64)
+			if n != lines || errX != nil || errY != nil || math.Abs(x-sum1) > 0.01 || math.Abs(y-sum2) > 0.01 {
+				t.Errorf("%s/%s: the parser read %s lines with sums %s, %s, want %d with %.2f, %.2f", delimiter, decimal, m[1], m[2], m[3], lines, sum1, sum2)
+			}
+		}
+	}
+}
+
+func TestFindFormat(t *testing.T) {
+	tests := []struct {
+		delimiter, decimal string
+		ok                 bool
+	}{
+		{"comma", "dot", true},
+		{"tab", "dot", true},
+		{"semicolon", "dot", true},
+		{"pipe", "dot", true},
+		{"semicolon", "comma", true},
+		{"comma", "comma", false},
+		{"tab", "comma", false},
+		{"pipe", "comma", false},
+		{"space", "dot", false},
+		{"comma", "point", false},
+	}
+	for _, tt := range tests {
+		_, err := findFormat(tt.delimiter, tt.decimal)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("findFormat(%q, %q) = %v, want ok %v", tt.delimiter, tt.decimal, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertToBinary(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "points.txt")
+	xs, ys := writePoints(t, text, 1001)
+	data, err := os.ReadFile(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(text, append([]byte("x,y\n"), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(dir, "points.bin")
+	rows, err := convertToBinary(text, bin, formats[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != int64(len(xs)) {
+		t.Fatalf("converted %d rows, want %d", rows, len(xs))
+	}
+
+	out, err := os.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != int(binaryHeaderSize)+4*len(xs) {
+		t.Fatalf("points.bin is %d bytes, want %d", len(out), int(binaryHeaderSize)+4*len(xs))
+	}
+	var wantX, wantY float64
+	for i := range xs {
+		x := int16(binary.LittleEndian.Uint16(out[binaryHeaderSize+2*int64(i):]))
+		y := int16(binary.LittleEndian.Uint16(out[binaryHeaderSize+2*int64(len(xs)+i):]))
+		if float64(x) != math.Round(xs[i]*100) || float64(y) != math.Round(ys[i]*100) {
+			t.Fatalf("row %d is %d, %d, want %v, %v", i, x, y, xs[i], ys[i])
+		}
+		wantX += xs[i]
+		wantY += ys[i]
+	}
+
+	sumX, sumY, lines := binaryReadAndSum(bin)
+	if lines != rows || math.Abs(sumX-wantX) > 1e-6 || math.Abs(sumY-wantY) > 1e-6 {
+		t.Errorf("binaryReadAndSum = %v, %v, %d, want %v, %v, %d", sumX, sumY, lines, wantX, wantY, rows)
+	}
+}
+
+func TestConvertToBinaryRejects(t *testing.T) {
+	for _, data := range []string{
+		"1.00,2.00\n1.005,2.00\n",  // Three decimals
+		"1.00,2.00\n400.00,2.00\n", // Outside int16 hundredths
+		"1.00,2.00\nx,y\n",         // A header after the first line
+	} {
+		dir := t.TempDir()
+		text := filepath.Join(dir, "points.txt")
+		if err := os.WriteFile(text, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := convertToBinary(text, filepath.Join(dir, "points.bin"), formats[0]); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("converting %q: error %v, want one about line 2", data, err)
+		}
+	}
+}
+
+func TestReadBinaryHeaderRejects(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "points.bin")
+	writePoints(t, filepath.Join(dir, "points.txt"), 100)
+	if _, err := convertToBinary(filepath.Join(dir, "points.txt"), bin, formats[0]); err != nil {
+		t.Fatal(err)
+	}
+	good, err := os.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+		err    string
+	}{
+		{"truncated", func(data []byte) []byte { return data[:len(data)-1] }, "its header says"},
+		{"trailing bytes", func(data []byte) []byte { return append(data, 0, 0) }, "its header says"},
+		{"short header", func(data []byte) []byte { return data[:10] }, "reading binary header"},
+		{"wrong magic", func(data []byte) []byte { data[0] = 'X'; return data }, "not a binary points file"},
+		{"wrong version", func(data []byte) []byte { data[4] = 2; return data }, "version 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "points.bin")
+			if err := os.WriteFile(path, tt.modify(append([]byte(nil), good...)), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			if _, err := readBinaryHeader(file); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+			if _, _, lines := binaryReadAndSum(path); lines != 0 {
+				t.Errorf("binaryReadAndSum read %d lines", lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sink keeps the compiler from dropping the loads of the touch benchmarks.
+var sink byte
+
+// inParallel splits [0, size) into one range per worker and waits for fn to
+// finish all of them.
+func inParallel(size int64, workers int, fn func(start, end int64) error) error {
+	perWorker := (size + int64(workers) - 1) / int64(workers)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for start := int64(0); start < size; start += perWorker {
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := fn(start, end); err != nil {
+				errs <- err
+			}
+		}(start, min(start+perWorker, size))
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// bestOf returns the shortest of reps timings of fn.
+func bestOf(reps int, fn func() error) (time.Duration, error) {
+	best := time.Duration(-1)
+	for i := 0; i < reps; i++ {
+		start := time.Now()
+		if err := fn(); err != nil {
+			return 0, err
+		}
+		if elapsed := time.Since(start); best < 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+	return best, nil
+}
+
+// memoryCopyRate measures how many bytes per second all cores together copy
+// from one buffer of size bytes to another.
+func memoryCopyRate(size int64, workers, reps int) (float64, error) {
+	src := make([]byte, size)
+	dst := make([]byte, size)
+	for i := range src {
+		src[i] = byte(i) // Fault the pages in before timing
+	}
+	copy(dst, src)
+
+	best, err := bestOf(reps, func() error {
+		return inParallel(size, workers, func(start, end int64) error {
+			copy(dst[start:end], src[start:end])
+			return nil
+		})
+	})
+	return float64(size) / best.Seconds(), err
+}
+
+// readRate measures how many bytes per second all cores together read() from
+// the file while it is in the page cache.
+func readRate(filePath string, size int64, workers, reps int) (float64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	buffers := make(chan []byte, workers)
+	for i := 0; i < workers; i++ {
+		buffers <- make([]byte, 1<<20)
+	}
+
+	best, err := bestOf(reps, func() error {
+		return inParallel(size, workers, func(start, end int64) error {
+			buffer := <-buffers
+			defer func() { buffers <- buffer }()
+			for offset := start; offset < end; {
+				n, err := file.ReadAt(buffer[:min(int64(len(buffer)), end-offset)], offset)
+				if err != nil && err != io.EOF {
+					return err
+				}
+				if n == 0 {
+					break
+				}
+				offset += int64(n)
+			}
+			return nil
+		})
+	})
+	return float64(size) / best.Seconds(), err
+}
+
+// boundCommand estimates the fastest possible time to get the input into the
+// process on this machine, the lower bound for any parsing strategy, and
+// shows how far each strategy is from it. The bound is the input size over
+// the faster of read() from the page cache and touching a mapping of the
+// file with all cores; the memory copy rate is shown for comparison, as
+// read() cannot beat it by much.
+func boundCommand(args []string) error {
+	flags := newFlagSet("bound")
+	filePath := flags.String("file", "points.txt", "file to measure the rates on")
+	reps := flags.Int("reps", 5, "repetitions of every rate measurement, the best one counts")
+	n := flags.Int("n", 1, "runs per strategy, the best one counts")
+	only := flags.String("strategies", "", "comma separated strategies to show (default all, including parse)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *reps < 1 || *n < 1 {
+		return fmt.Errorf("-reps and -n must be positive")
+	}
+	stat, err := os.Stat(*filePath)
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	if size == 0 {
+		return fmt.Errorf("%s is empty", *filePath)
+	}
+	workers := runtime.NumCPU()
+
+	// The rate does not depend on the size once it is well beyond the caches.
+	copyRate, err := memoryCopyRate(min(size, 256<<20), workers, *reps)
+	if err != nil {
+		return err
+	}
+	cachedReadRate, err := readRate(*filePath, size, workers, *reps)
+	if err != nil {
+		return err
+	}
+	mmapRate, mmapErr := mmapTouchRate(*filePath, size, workers, *reps)
+
+	gbps := func(rate float64) string { return fmt.Sprintf("%.2f GB/s", rate/1e9) }
+	fmt.Printf("file: %s, %d bytes, %d workers\n", *filePath, size, workers)
+	fmt.Printf("memory copy:            %s\n", gbps(copyRate))
+	fmt.Printf("read() from page cache: %s\n", gbps(cachedReadRate))
+	fileRate, how := cachedReadRate, "read()"
+	if mmapErr != nil {
+		fmt.Printf("mmap touch:             %v\n", mmapErr)
+	} else {
+		fmt.Printf("mmap touch:             %s\n", gbps(mmapRate))
+		if mmapRate > fileRate {
+			fileRate, how = mmapRate, "mmap"
+		}
+	}
+	bound := time.Duration(float64(size) / fileRate * 1e9)
+	fmt.Printf("lower bound: %v (%s)\n\n", bound, how)
+
+	var selected []functionInfo
+	if *only == "" {
+		selected = append([]functionInfo{parseStrategy}, strategies...)
+	} else {
+		for _, name := range strings.Split(*only, ",") {
+			fi, err := findStrategy(strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			selected = append(selected, fi)
+		}
+	}
+
+	fmt.Printf("%-40s %12s %12s %12s\n", "strategy", "best", "bound", "% of bound")
+	for _, fi := range selected {
+		stat, err := os.Stat(fi.file)
+		if err != nil {
+			fmt.Printf("%-40s %v\n", fi.name, err)
+			continue
+		}
+		// Strategies reading another file, e.g. points.bin, are held to the
+		// bound for that file's size.
+		fileBound := time.Duration(float64(stat.Size()) / fileRate * 1e9)
+		best, _ := bestOf(*n, func() error {
+			fi.function(fi.file)
+			return nil
+		})
+		fmt.Printf("%-40s %12v %12v %11.0f%%\n", fi.name, best.Round(time.Microsecond),
+			fileBound.Round(time.Microsecond), 100*float64(best)/float64(fileBound))
+	}
+	return nil
+}
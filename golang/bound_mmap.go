@@ -0,0 +1,48 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapTouchRate measures how many bytes per second all cores together bring
+// in by mapping the file and loading one byte of every 64 byte cache line,
+// which faults in every page of the file and streams all of it through the
+// caches.
+func mmapTouchRate(filePath string, size int64, workers, reps int) (float64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	best, err := bestOf(reps, func() error {
+		data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+		if err != nil {
+			return err
+		}
+		defer syscall.Munmap(data)
+
+		sums := make([]byte, workers)
+		next := make(chan int, workers)
+		for i := range sums {
+			next <- i
+		}
+		err = inParallel(size, workers, func(start, end int64) error {
+			i := <-next
+			var sum byte
+			for j := start; j < end; j += 64 {
+				sum += data[j]
+			}
+			sums[i] = sum
+			return nil
+		})
+		for _, s := range sums {
+			sink += s
+		}
+		return err
+	})
+	return float64(size) / best.Seconds(), err
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "errors"
+
+func mmapTouchRate(filePath string, size int64, workers, reps int) (float64, error) {
+	return 0, errors.New("not supported on this platform")
+}
@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// Cache modes of the measurements. Warm runs read the file from the page
+// cache after the first run, as the repetition tester always did, so they
+// hide the cost of I/O. Cold runs evict the file before every run so that
+// it is read from the disk.
+const (
+	cacheWarm = "warm"
+	cacheCold = "cold"
+	cacheBoth = "both" // Cold runs, then warm runs, reported separately
+)
+
+func checkCacheMode(mode string, allowBoth bool) error {
+	switch {
+	case mode == cacheWarm, mode == cacheCold:
+		return nil
+	case mode == cacheBoth && allowBoth:
+		return nil
+	case allowBoth:
+		return fmt.Errorf("unknown cache mode %q (want warm, cold or both)", mode)
+	}
+	return fmt.Errorf("unknown cache mode %q (want warm or cold)", mode)
+}
+
+// evictFile removes path from the page cache before a cold run, with
+// posix_fadvise(POSIX_FADV_DONTNEED) or, where that is not available,
+// by dropping all caches, which needs root. It then checks that the file is
+// really gone from the cache where that can be checked.
+func evictFile(path string) error {
+	err := fadviseDontNeed(path)
+	if err != nil {
+		if dropErr := dropCaches(); dropErr != nil {
+			return fmt.Errorf("cannot evict %s from the page cache: %v; dropping all caches: %v", path, err, dropErr)
+		}
+	}
+
+	resident, err := residentFraction(path)
+	if err != nil {
+		return err
+	}
+	// A few pages may be read back by readahead or another process.
+	if resident > 0.01 {
+		return fmt.Errorf("%.1f%% of %s is still in the page cache after evicting it", 100*resident, path)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const posixFadvDontNeed = 4
+
+// fadviseDontNeed tells the kernel that the pages of path are not needed any
+// more. Dirty pages cannot be dropped, so the file is synced first.
+func fadviseDontNeed(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, file.Fd(), 0, 0, posixFadvDontNeed, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// dropCaches drops the whole page cache, which only root may do.
+func dropCaches() error {
+	syscall.Sync()
+	return os.WriteFile("/proc/sys/vm/drop_caches", []byte("1\n"), 0)
+}
+
+// residentFraction returns the fraction of the pages of path that are in
+// the page cache, using mincore on a read-only mapping of the file.
+func residentFraction(path string) (float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.Size() == 0 {
+		return 0, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(stat.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Munmap(data)
+
+	pageSize := os.Getpagesize()
+	pages := make([]byte, (len(data)+pageSize-1)/pageSize)
+	_, _, errno := syscall.Syscall(syscall.SYS_MINCORE, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&pages[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+	resident := 0
+	for _, p := range pages {
+		resident += int(p & 1)
+	}
+	return float64(resident) / float64(len(pages)), nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import "errors"
+
+func fadviseDontNeed(path string) error {
+	return errors.New("posix_fadvise is not supported on this platform")
+}
+
+func dropCaches() error {
+	return errors.New("dropping caches is not supported on this platform")
+}
+
+// residentFraction cannot be measured here; 0 lets evictFile trust the
+// eviction.
+func residentFraction(path string) (float64, error) {
+	return 0, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckCacheMode(t *testing.T) {
+	tests := []struct {
+		mode      string
+		allowBoth bool
+		ok        bool
+	}{
+		{cacheWarm, false, true},
+		{cacheCold, false, true},
+		{cacheBoth, true, true},
+		{cacheBoth, false, false},
+		{"hot", true, false},
+	}
+	for _, tt := range tests {
+		if err := checkCacheMode(tt.mode, tt.allowBoth); (err == nil) != tt.ok {
+			t.Errorf("checkCacheMode(%q, %v) = %v, want ok %v", tt.mode, tt.allowBoth, err, tt.ok)
+		}
+	}
+}
+
+func TestEvictFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("12.34,-56.78\n"), 1<<16), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fadviseDontNeed(path); err != nil {
+		t.Skip("posix_fadvise is not available:", err)
+	}
+	if _, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	before, err := residentFraction(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == 0 {
+		t.Skip("the page cache residency cannot be measured here")
+	}
+
+	if err := evictFile(path); err != nil {
+		t.Fatal(err)
+	}
+	after, err := residentFraction(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after > 0.01 {
+		t.Errorf("%.1f%% of the file is still resident after evictFile, %.1f%% before", 100*after, 100*before)
+	}
+}
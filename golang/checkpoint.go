@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// A checkpoint file records the partial sums of every chunk as soon as it is
+// done, so an interrupted parse of a huge file can resume with the chunks that
+// are still missing. It starts with a checkpointHeader describing the file and
+// the chunking, followed by one checkpointRecord per finished chunk in
+// completion order. Each record is synced to disk before the next one is
+// written and carries a CRC, so a record torn by a crash is detected and its
+// chunk parsed again.
+//
+// The chunks only depend on the file and the chunk size, and the totals are
+// summed in offset order, so a resumed run prints exactly the same totals as
+// one that was never interrupted.
+const checkpointVersion = 1
+
+var checkpointMagic = [4]byte{'P', 'C', 'K', 'P'}
+
+type checkpointHeader struct {
+	Magic     [4]byte
+	Version   uint32
+	Delimiter byte
+	Decimal   byte
+	_         [6]byte
+	ChunkSize int64
+	FileSize  int64
+	ModTime   int64 // Unix nanoseconds
+}
+
+type checkpointRecord struct {
+	Offset int64
+	Size   int64
+	SumX   float64
+	SumY   float64
+	Lines  int64
+	CRC    uint32 // Of the fields above
+	_      [4]byte
+}
+
+var checkpointRecordSize = int64(binary.Size(checkpointRecord{}))
+
+func (r checkpointRecord) checksum() uint32 {
+	r.CRC = 0
+	b, _ := binary.Append(nil, binary.LittleEndian, r)
+	return crc32.ChecksumIEEE(b[:checkpointRecordSize-8])
+}
+
+// loadCheckpoint returns the valid records of the checkpoint at path and the
+// length of the file they fill. ok is false when there is no checkpoint or it
+// was written for another file, format or chunk size.
+func loadCheckpoint(path string, want checkpointHeader) (records []checkpointRecord, size int64, ok bool, err error) {
+	in, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	var h checkpointHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil || h != want {
+		return nil, 0, false, nil
+	}
+	size = int64(binary.Size(h))
+
+	for {
+		var rec checkpointRecord
+		if err := binary.Read(r, binary.LittleEndian, &rec); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, 0, false, err
+		}
+		if rec.CRC != rec.checksum() {
+			break // Torn by a crash, everything after it is suspect too
+		}
+		records = append(records, rec)
+		size += checkpointRecordSize
+	}
+	return records, size, true, nil
+}
+
+// checkpointReadAndSum sums the file in chunks of about chunkSize bytes,
+// skipping the chunks already recorded in the checkpoint and recording the
+// rest as they complete. It returns the totals and how many chunks were
+// taken from the checkpoint.
+func checkpointReadAndSum(filePath, checkpointPath string, f format, chunkSize int64) (float64, float64, int64, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	header := checkpointHeader{
+		Magic:     checkpointMagic,
+		Version:   checkpointVersion,
+		Delimiter: f.delimiter,
+		Decimal:   f.decimal,
+		ChunkSize: chunkSize,
+		FileSize:  stat.Size(),
+		ModTime:   stat.ModTime().UnixNano(),
+	}
+	records, validSize, ok, err := loadCheckpoint(checkpointPath, header)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	var out *os.File
+	if ok {
+		out, err = os.OpenFile(checkpointPath, os.O_WRONLY, 0o644)
+		if err == nil {
+			err = out.Truncate(validSize)
+		}
+		if err == nil {
+			_, err = out.Seek(validSize, io.SeekStart)
+		}
+	} else {
+		if _, statErr := os.Stat(checkpointPath); statErr == nil {
+			fmt.Fprintf(os.Stderr, "%s belongs to another file or settings, starting over\n", checkpointPath)
+		}
+		out, err = os.Create(checkpointPath)
+		if err == nil {
+			err = binary.Write(out, binary.LittleEndian, header)
+		}
+		if err == nil {
+			err = out.Sync()
+		}
+	}
+	if err != nil {
+		if out != nil {
+			out.Close()
+		}
+		return 0, 0, 0, 0, err
+	}
+	defer out.Close()
+
+	numChunks := int(max(1, (stat.Size()+chunkSize-1)/chunkSize))
+	chunks, err := splitChunks(file, 0, stat.Size(), numChunks)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	done := make(map[chunk]bool, len(records))
+	for _, rec := range records {
+		done[chunk{offset: rec.Offset, size: rec.Size}] = true
+	}
+	resumed := 0
+	jobs := make(chan chunk, len(chunks))
+	for _, c := range chunks {
+		if done[c] {
+			resumed++
+		} else {
+			jobs <- c
+		}
+	}
+	close(jobs)
+
+	type result struct {
+		rec checkpointRecord
+		err error
+	}
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buffer []byte
+			for c := range jobs {
+				if int64(cap(buffer)) < c.size {
+					buffer = make([]byte, c.size)
+				}
+				data := buffer[:c.size]
+				if _, err := file.ReadAt(data, c.offset); err != nil && err != io.EOF {
+					results <- result{err: err}
+					continue
+				}
+				x, y, lines := f.sumChunk(data)
+				results <- result{rec: checkpointRecord{Offset: c.offset, Size: c.size, SumX: x, SumY: y, Lines: lines}}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		if err == nil {
+			err = res.err
+		}
+		if err != nil {
+			continue // Drain the workers
+		}
+		res.rec.CRC = res.rec.checksum()
+		if err = binary.Write(out, binary.LittleEndian, res.rec); err == nil {
+			err = out.Sync()
+		}
+		records = append(records, res.rec)
+	}
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Offset < records[j].Offset })
+	var sumX, sumY float64
+	var lines int64
+	for _, rec := range records {
+		sumX += rec.SumX
+		sumY += rec.SumY
+		lines += rec.Lines
+	}
+	return sumX, sumY, lines, resumed, nil
+}
+
+// checkpointCommand sums a points file like the sum command, persisting the
+// progress so that running it again after an interruption only parses the
+// chunks that were not finished. The checkpoint is removed once the totals
+// are printed unless -keep is given.
+func checkpointCommand(args []string) error {
+	flags := newFlagSet("checkpoint")
+	in := addInputFlags(flags, "file to parse")
+	checkpointPath := flags.String("checkpoint", "", "checkpoint file (default the file name with .ckpt appended)")
+	chunkSize := flags.Int64("chunk-size", 64<<20, "bytes per chunk; each finished chunk is one checkpoint record")
+	keep := flags.Bool("keep", false, "keep the checkpoint file after a complete run")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *chunkSize < 1 {
+		return fmt.Errorf("-chunk-size must be positive")
+	}
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = *in.file + ".ckpt"
+	}
+
+	sumX, sumY, lines, resumed, err := checkpointReadAndSum(*in.file, *checkpointPath, f, *chunkSize)
+	if err != nil {
+		return err
+	}
+	if resumed > 0 {
+		fmt.Printf("resumed: %d chunks from %s\n", resumed, *checkpointPath)
+	}
+	fmt.Printf("lines: %d\n", lines)
+	fmt.Printf("x: sum %.2f avg %.6f\n", sumX, sumX/float64(lines))
+	fmt.Printf("y: sum %.2f avg %.6f\n", sumY, sumY/float64(lines))
+
+	if !*keep {
+		return os.Remove(*checkpointPath)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestCheckpointResume interrupts a run by cutting its checkpoint short after
+// some of the records, as a crash would, and checks that resuming it gives
+// exactly the totals of a run that was never interrupted.
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "points.txt")
+	writePoints(t, path, 5000)
+	const chunkSize = 4096
+
+	full := filepath.Join(dir, "full.ckpt")
+	wantX, wantY, wantLines, _, err := checkpointReadAndSum(path, full, formats[0], chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantLines != 5000 {
+		t.Fatalf("summed %d lines, want 5000", wantLines)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerSize := int64(binary.Size(checkpointHeader{}))
+	records := int((int64(len(data)) - headerSize) / checkpointRecordSize)
+	if records < 4 {
+		t.Fatalf("only %d chunks, the file is too small to interrupt", records)
+	}
+
+	tests := []struct {
+		name string
+		kept int    // Records that survive the interruption
+		torn []byte // Bytes of a record being written when it happened
+	}{
+		{"no records", 0, nil},
+		{"half the records", records / 2, nil},
+		{"all but one", records - 1, nil},
+		{"torn record", records / 2, data[headerSize+int64(records/2)*checkpointRecordSize:][:checkpointRecordSize/2]},
+		{"corrupt record", records / 2, make([]byte, checkpointRecordSize)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ckpt := filepath.Join(t.TempDir(), "points.ckpt")
+			partial := append([]byte(nil), data[:headerSize+int64(tt.kept)*checkpointRecordSize]...)
+			if err := os.WriteFile(ckpt, append(partial, tt.torn...), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			sumX, sumY, lines, resumed, err := checkpointReadAndSum(path, ckpt, formats[0], chunkSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resumed != tt.kept {
+				t.Errorf("resumed %d chunks, want %d", resumed, tt.kept)
+			}
+			if sumX != wantX || sumY != wantY || lines != wantLines {
+				t.Errorf("resumed run = %v, %v, %d, uninterrupted run = %v, %v, %d", sumX, sumY, lines, wantX, wantY, wantLines)
+			}
+
+			// The checkpoint is complete now, so another run parses nothing.
+			if _, _, _, resumed, err := checkpointReadAndSum(path, ckpt, formats[0], chunkSize); err != nil || resumed != records {
+				t.Errorf("run after the resumed one took %d chunks from the checkpoint, want %d (err %v)", resumed, records, err)
+			}
+		})
+	}
+}
+
+// TestCheckpointKilled runs the checkpoint command in another process, kills
+// it with SIGKILL once its checkpoint holds some records and checks that
+// running the command again resumes from them and prints the totals of an
+// uninterrupted run.
+func TestCheckpointKilled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "points.txt")
+	writePoints(t, path, 200000)
+	ckpt := path + ".ckpt"
+	args := []string{"checkpoint", "-file", path, "-chunk-size", "1024"}
+	const killAfter = 20
+
+	want := runHelper(t, append(args, "-checkpoint", filepath.Join(dir, "full.ckpt"))...)
+
+	cmd := helperCommand(args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	headerSize := int64(binary.Size(checkpointHeader{}))
+	deadline := time.Now().Add(time.Minute)
+	for {
+		if stat, err := os.Stat(ckpt); err == nil && stat.Size() >= headerSize+killAfter*checkpointRecordSize {
+			break
+		}
+		select {
+		case err := <-exited:
+			t.Fatalf("the run ended before it was killed: %v", err)
+		case <-time.After(time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			t.Fatal("no checkpoint records after a minute")
+		}
+	}
+	if err := cmd.Process.Signal(syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	<-exited
+
+	got := runHelper(t, args...)
+	resumed, rest, _ := strings.Cut(got, "\n")
+	var chunks int
+	if _, err := fmt.Sscanf(resumed, "resumed: %d chunks", &chunks); err != nil || chunks < killAfter {
+		t.Errorf("first line of the resumed run is %q, want at least %d resumed chunks", resumed, killAfter)
+	}
+	if rest != want {
+		t.Errorf("resumed run printed\n%s\nuninterrupted run printed\n%s", rest, want)
+	}
+	if _, err := os.Stat(ckpt); !os.IsNotExist(err) {
+		t.Errorf("the checkpoint is still there after a complete run: %v", err)
+	}
+}
+
+// TestCheckpointHelperProcess is the command run by helperCommand, not a test.
+func TestCheckpointHelperProcess(t *testing.T) {
+	if os.Getenv("PARSER_HELPER_PROCESS") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	runCommand(args[1], args[2:])
+	os.Exit(0)
+}
+
+// helperCommand runs a parser command in a copy of the test binary.
+func helperCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestCheckpointHelperProcess$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), "PARSER_HELPER_PROCESS=1")
+	return cmd
+}
+
+// runHelper runs a parser command to completion and returns its output.
+func runHelper(t *testing.T, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := helperCommand(args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("%v: %v\n%s", args, err, stderr.String())
+	}
+	return stdout.String()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// chunk is a byte range of a file that starts at the beginning of a line and
+// ends right after a '\n' (or at the end of the file).
+type chunk struct {
+	offset int64
+	size   int64
+}
+
+// splitChunks cuts [start, end) of file into at most n line-aligned chunks of
+// roughly equal size, so that no line is split between two workers.
+func splitChunks(file *os.File, start, end int64, n int) ([]chunk, error) {
+	chunkSize := (end - start) / int64(n)
+	if chunkSize == 0 {
+		n = 1
+	}
+
+	probe := make([]byte, 256)
+	chunks := make([]chunk, 0, n)
+	offset := start
+
+	for i := 1; i < n; i++ {
+		boundary := start + int64(i)*chunkSize
+		if boundary <= offset {
+			continue // The previous line was longer than a whole chunk
+		}
+		next, err := nextLineStart(file, boundary, end, probe)
+		if err != nil {
+			return nil, err
+		}
+		if next >= end {
+			break
+		}
+		chunks = append(chunks, chunk{offset: offset, size: next - offset})
+		offset = next
+	}
+
+	if offset < end {
+		chunks = append(chunks, chunk{offset: offset, size: end - offset})
+	}
+	return chunks, nil
+}
+
+// nextLineStart returns the offset of the first byte after the next '\n' at or
+// after pos, or end if there is none.
+func nextLineStart(file *os.File, pos, end int64, probe []byte) (int64, error) {
+	for pos < end {
+		n, err := file.ReadAt(probe, pos)
+		if idx := bytes.IndexByte(probe[:n], '\n'); idx != -1 {
+			return pos + int64(idx) + 1, nil
+		}
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		pos += int64(n)
+	}
+	return end, nil
+}
+
+// forEachChunk splits [start, end) of file into line-aligned chunks, reads
+// each one with ReadAt in its own goroutine and hands its bytes to fn.
+// fn runs concurrently and is called at most numWorkers times. When the file
+// has an up to date line index the chunk boundaries are taken from it.
+func forEachChunk(file *os.File, start, end int64, numWorkers int, fn func(data []byte)) error {
+	var chunks []chunk
+	if idx := cachedIndex(file.Name()); idx != nil {
+		chunks = idx.chunks(start, end, numWorkers)
+	} else {
+		var err error
+		chunks, err = splitChunks(file, start, end, numWorkers)
+		if err != nil {
+			return err
+		}
+	}
+
+	errs := make(chan error, len(chunks))
+	var wg sync.WaitGroup
+
+	for _, c := range chunks {
+		wg.Add(1)
+		go func(c chunk) {
+			defer wg.Done()
+			buffer := make([]byte, c.size)
+			if _, err := file.ReadAt(buffer, c.offset); err != nil && err != io.EOF {
+				errs <- err
+				return
+			}
+			fn(buffer)
+		}(c)
+	}
+
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// mapChunks runs fn on every line-aligned chunk of [start, end) of file, at
+// most numWorkers of them, and returns what each call returned, in no
+// particular order.
+func mapChunks[T any](file *os.File, start, end int64, numWorkers int, fn func(data []byte) T) ([]T, error) {
+	results := make(chan T, numWorkers)
+	err := forEachChunk(file, start, end, numWorkers, func(data []byte) {
+		results <- fn(data)
+	})
+	close(results)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]T, 0, len(results))
+	for res := range results {
+		all = append(all, res)
+	}
+	return all, nil
+}
+
+// readChunks is mapChunks over the whole file at filePath, one chunk per CPU.
+func readChunks[T any](filePath string, fn func(data []byte) T) ([]T, error) {
+	return readChunksN(filePath, runtime.NumCPU(), fn)
+}
+
+// readChunksN is readChunks with at most numWorkers chunks.
+func readChunksN[T any](filePath string, numWorkers int, fn func(data []byte) T) ([]T, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return mapChunks(file, 0, stat.Size(), numWorkers, fn)
+}
+
+// forEachPoint calls fn with both values of every line of data that holds a
+// point in format f. Other lines, such as a header, are skipped.
+func forEachPoint(data []byte, f format, fn func(x, y float64)) {
+	lineStart := 0
+	for i := 0; i <= len(data); i++ {
+		if i == len(data) || data[i] == '\n' {
+			if x, y, ok := f.parsePoint(data[lineStart:i]); ok {
+				fn(x, y)
+			}
+			lineStart = i + 1
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	writePoints(t, path, 1000)
+	long := filepath.Join(t.TempDir(), "long.txt")
+	if err := os.WriteFile(long, []byte("1.00,2.00\n"+strings.Repeat("9", 5000)+",1\n3.00,4.00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path, long} {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		for n := 1; n <= 16; n++ {
+			chunks, err := splitChunks(file, 0, int64(len(data)), n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) == 0 || len(chunks) > n {
+				t.Fatalf("%s: %d chunks for %d workers", p, len(chunks), n)
+			}
+			var offset int64
+			for i, c := range chunks {
+				if c.offset != offset || c.size <= 0 {
+					t.Fatalf("%s, %d workers: chunk %d is %+v, want it to start at %d", p, n, i, c, offset)
+				}
+				offset += c.size
+				if i < len(chunks)-1 && data[offset-1] != '\n' {
+					t.Errorf("%s, %d workers: chunk %d ends inside a line", p, n, i)
+				}
+			}
+			if offset != int64(len(data)) {
+				t.Errorf("%s, %d workers: chunks end at %d, want %d", p, n, offset, len(data))
+			}
+		}
+	}
+}
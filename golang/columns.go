@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// headerMode says whether the first line of a file holds column names.
+type headerMode int
+
+const (
+	headerAuto    headerMode = iota // Header if any field of the first line is not a number
+	headerPresent                   // The first line is always a header
+	headerAbsent                    // The first line is always data
+)
+
+func parseHeaderMode(s string) (headerMode, error) {
+	switch s {
+	case "auto":
+		return headerAuto, nil
+	case "yes":
+		return headerPresent, nil
+	case "no":
+		return headerAbsent, nil
+	}
+	return 0, fmt.Errorf("unknown header mode %q (want auto, yes or no)", s)
+}
+
+// columnSums holds the per-column sums of a parse, keyed by column name.
+type columnSums struct {
+	names []string
+	sums  []float64
+	lines int64
+}
+
+// averages returns the average of every column keyed by its name, or nil
+// when there were no lines to average.
+func (c columnSums) averages() map[string]float64 {
+	if c.lines == 0 {
+		return nil
+	}
+	avgs := make(map[string]float64, len(c.names))
+	for i, name := range c.names {
+		avgs[name] = c.sums[i] / float64(c.lines)
+	}
+	return avgs
+}
+
+// readFirstLine returns the first line of the file without its line ending.
+func readFirstLine(file *os.File, fileSize int64) ([]byte, int64, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(file, 0, fileSize)).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	return trimLine(bytes.TrimSuffix(line, []byte{'\n'})), int64(len(line)), nil
+}
+
+// splitFields splits a line on the delimiter and trims the spaces around each
+// field.
+func splitFields(line []byte, delimiter byte) []string {
+	var fields []string
+	for {
+		idx := findByte(line, delimiter)
+		if idx == -1 {
+			return append(fields, string(trimField(line)))
+		}
+		fields = append(fields, string(trimField(line[:idx])))
+		line = line[idx+1:]
+	}
+}
+
+// looksLikeHeader reports whether any of the fields is not a number written
+// with point as its decimal separator.
+func looksLikeHeader(fields []string, point byte) bool {
+	for _, f := range fields {
+		if _, ok := parseDecimal([]byte(f), point); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// namedColumnsReadAndSum sums the selected columns of a file in format f that
+// may start with a header line such as "x,y". Columns are selected by name; an
+// empty selection means all of them. Files without a header name their
+// columns col1, col2, ...
+// Only the selected fields are decoded; the rest of a line is skipped as soon
+// as the last selected field was parsed.
+func namedColumnsReadAndSum(filePath string, f format, header headerMode, selected []string) (columnSums, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return columnSums{}, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return columnSums{}, err
+	}
+
+	first, firstLen, err := readFirstLine(file, stat.Size())
+	if err != nil {
+		return columnSums{}, err
+	}
+	fields := splitFields(first, f.delimiter)
+
+	hasHeader := header == headerPresent || (header == headerAuto && looksLikeHeader(fields, f.decimal))
+	names := fields
+	dataStart := firstLen
+	if !hasHeader {
+		names = make([]string, len(fields))
+		for i := range names {
+			names[i] = fmt.Sprintf("col%d", i+1)
+		}
+		dataStart = 0
+	}
+
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := index[name]; ok {
+			return columnSums{}, fmt.Errorf("column %q appears more than once in the header", name)
+		}
+		index[name] = i
+	}
+
+	// want holds the indices of the selected columns in file order
+	want := make([]int, 0, len(names))
+	if len(selected) == 0 {
+		for i := range names {
+			want = append(want, i)
+		}
+	} else {
+		picked := make(map[string]bool, len(selected))
+		var unknown []string
+		for _, s := range selected {
+			if picked[s] {
+				return columnSums{}, fmt.Errorf("column %q is selected more than once", s)
+			}
+			picked[s] = true
+			if _, ok := index[s]; !ok {
+				unknown = append(unknown, s)
+			}
+		}
+		if len(unknown) > 0 {
+			return columnSums{}, fmt.Errorf("unknown column %s (file has %s)", strings.Join(unknown, ", "), strings.Join(names, ", "))
+		}
+		for i, name := range names {
+			if picked[name] {
+				want = append(want, i)
+			}
+		}
+	}
+
+	type partial struct {
+		sums  []float64
+		lines int64
+	}
+	results, err := mapChunks(file, dataStart, stat.Size(), runtime.NumCPU(), func(data []byte) partial {
+		local := partial{sums: make([]float64, len(want))}
+		values := make([]float64, len(want))
+
+		lineStart := 0
+		for i := 0; i <= len(data); i++ {
+			if i < len(data) && data[i] != '\n' {
+				continue
+			}
+			line := trimLine(data[lineStart:i])
+			lineStart = i + 1
+			if len(line) == 0 {
+				continue
+			}
+
+			field, next := 0, 0
+			valid := true
+			for next < len(want) {
+				end := findByte(line, f.delimiter)
+				value := line
+				if end != -1 {
+					value = line[:end]
+				}
+				if field == want[next] {
+					values[next], valid = parseDecimal(trimField(value), f.decimal)
+					if !valid {
+						break
+					}
+					next++
+				}
+				if end == -1 {
+					break
+				}
+				line = line[end+1:]
+				field++
+			}
+			if !valid || next < len(want) {
+				continue // Skip lines missing a selected column or with a non-number in one
+			}
+
+			for j, v := range values {
+				local.sums[j] += v
+			}
+			local.lines++
+		}
+		return local
+	})
+	if err != nil {
+		return columnSums{}, err
+	}
+
+	total := columnSums{sums: make([]float64, len(want))}
+	for _, i := range want {
+		total.names = append(total.names, names[i])
+	}
+	for _, res := range results {
+		for j, s := range res.sums {
+			total.sums[j] += s
+		}
+		total.lines += res.lines
+	}
+
+	return total, nil
+}
+
+// columnsReadAndSum sums the selected columns over several files, matching
+// them by name, so the columns of the files may come in any order. Every file
+// has to yield the same set of names.
+func columnsReadAndSum(paths []string, f format, header headerMode, selected []string) (columnSums, error) {
+	var total columnSums
+	for i, path := range paths {
+		res, err := namedColumnsReadAndSum(path, f, header, selected)
+		if err != nil {
+			return columnSums{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if i == 0 {
+			total = res
+			continue
+		}
+		index := make(map[string]int, len(res.names))
+		for j, name := range res.names {
+			index[name] = j
+		}
+		for _, name := range total.names {
+			if _, ok := index[name]; !ok || len(res.names) != len(total.names) {
+				return columnSums{}, fmt.Errorf("%s has columns %s, %s has %s", path, strings.Join(res.names, ", "),
+					paths[0], strings.Join(total.names, ", "))
+			}
+		}
+		for j, name := range total.names {
+			total.sums[j] += res.sums[index[name]]
+		}
+		total.lines += res.lines
+	}
+	return total, nil
+}
+
+// columnsCommand prints the average of every selected column by name, over
+// -file or over the files, globs and directories given after the flags.
+func columnsCommand(args []string) error {
+	flags := newFlagSet("columns")
+	in := addInputFlags(flags, "file to parse when no files are given after the flags")
+	header := flags.String("header", "auto", "whether the first line holds column names: auto, yes or no")
+	columns := flags.String("columns", "", "comma separated names of the columns to average (default all)")
+	pattern := flags.String("pattern", "*.txt", "names of the files to take from directories")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: parser columns [flags] [file|glob|directory...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+	mode, err := parseHeaderMode(*header)
+	if err != nil {
+		return err
+	}
+	var selected []string
+	if *columns != "" {
+		selected = strings.Split(*columns, ",")
+	}
+	paths := []string{*in.file}
+	if flags.NArg() > 0 {
+		if paths, err = expandInputs(flags.Args(), *pattern); err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no input files found")
+		}
+	}
+
+	res, err := columnsReadAndSum(paths, f, mode, selected)
+	if err != nil {
+		return err
+	}
+	if res.lines == 0 {
+		return fmt.Errorf("%s has no data lines to average", strings.Join(paths, ", "))
+	}
+
+	avgs := res.averages()
+	if len(paths) > 1 {
+		fmt.Printf("files: %d\n", len(paths))
+	}
+	fmt.Printf("lines: %d\n", res.lines)
+	for i, name := range res.names {
+		fmt.Printf("%s: sum %.2f avg %.6f\n", name, res.sums[i], avgs[name])
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamedColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		header   headerMode
+		selected []string
+		names    []string
+		sums     []float64
+		lines    int64
+		err      string // Part of the error, if one is expected
+	}{
+		{"all", "x,y,z\n1,2,3\n4,5,6\n", headerAuto, nil, []string{"x", "y", "z"}, []float64{5, 7, 9}, 2, ""},
+		{"selected in file order", "x,y,z\n1,2,3\n4,5,6\n", headerAuto, []string{"z", "x"}, []string{"x", "z"}, []float64{5, 9}, 2, ""},
+		{"no header", "1,2\n3,4\n", headerAuto, []string{"col2"}, []string{"col2"}, []float64{6}, 2, ""},
+		{"skipped lines", "x,y\n1,2\n3\nfoo,4\n5,6\n", headerAuto, nil, []string{"x", "y"}, []float64{6, 8}, 2, ""},
+		{"header only", "x,y\n", headerAuto, nil, []string{"x", "y"}, []float64{0, 0}, 0, ""},
+		{"empty file", "", headerAbsent, nil, []string{"col1"}, []float64{0}, 0, ""},
+		{"unknown", "x,y\n1,2\n", headerAuto, []string{"x", "w", "v"}, nil, nil, 0, "unknown column w, v"},
+		{"selected twice", "x,y\n1,2\n", headerAuto, []string{"x", "x"}, nil, nil, 0, `column "x" is selected more than once`},
+		{"named twice", "x,x\n1,2\n", headerAuto, []string{"x"}, nil, nil, 0, `column "x" appears more than once`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "points.txt")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			res, err := namedColumnsReadAndSum(path, formats[0], tt.header, tt.selected)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.names, tt.names) || !reflect.DeepEqual(res.sums, tt.sums) || res.lines != tt.lines {
+				t.Errorf("got %v %v %d lines, want %v %v %d", res.names, res.sums, res.lines, tt.names, tt.sums, tt.lines)
+			}
+			if avgs := res.averages(); (avgs == nil) != (tt.lines == 0) {
+				t.Errorf("averages of %d lines = %v", res.lines, avgs)
+			}
+		})
+	}
+}
+
+func TestNamedColumnsFormats(t *testing.T) {
+	data := "x,y,z\n1.50,-2.25,3\n4.25,5,6.75\n"
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "points.txt")
+			text := strings.NewReplacer(",", string(f.delimiter), ".", string(f.decimal)).Replace(data)
+			if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			res, err := namedColumnsReadAndSum(path, f, headerAuto, []string{"y", "z"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []float64{2.75, 9.75}; !reflect.DeepEqual(res.names, []string{"y", "z"}) || !reflect.DeepEqual(res.sums, want) || res.lines != 2 {
+				t.Errorf("got %v %v %d lines, want [y z] %v 2", res.names, res.sums, res.lines, want)
+			}
+		})
+	}
+
+	// Without a header, "1,50;2,25" is data in the european format.
+	path := filepath.Join(t.TempDir(), "points.txt")
+	if err := os.WriteFile(path, []byte("1,50;2,25\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := namedColumnsReadAndSum(path, formats[4], headerAuto, nil)
+	if err != nil || res.lines != 1 || !reflect.DeepEqual(res.sums, []float64{1.5, 2.25}) {
+		t.Errorf("got %v %d lines, %v, want [1.5 2.25] in 1 line", res.sums, res.lines, err)
+	}
+}
+
+func TestColumnsReadAndSum(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":     "x,y\n1,2\n3,4\n",
+		"b.txt":     "y,x\n10,20\n",
+		"c.txt":     "x,z\n1,2\n",
+		"empty.txt": "x,y\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	res, err := columnsReadAndSum([]string{path("a.txt"), path("b.txt"), path("empty.txt")}, formats[0], headerAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.names, []string{"x", "y"}) || res.lines != 3 {
+		t.Fatalf("got %v over %d lines, want [x y] over 3", res.names, res.lines)
+	}
+	if want := map[string]float64{"x": 24.0 / 3, "y": 16.0 / 3}; !reflect.DeepEqual(res.averages(), want) {
+		t.Errorf("averages %v, want %v", res.averages(), want)
+	}
+
+	_, err = columnsReadAndSum([]string{path("a.txt"), path("c.txt")}, formats[0], headerAuto, nil)
+	if want := "has columns x, z"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error %v, want one containing %q", err, want)
+	}
+	res, err = columnsReadAndSum([]string{path("a.txt"), path("c.txt")}, formats[0], headerAuto, []string{"x"})
+	if err != nil || res.lines != 3 || !reflect.DeepEqual(res.sums, []float64{5}) {
+		t.Errorf("selecting x: got %v over %d lines, %v, want [5] over 3", res.sums, res.lines, err)
+	}
+	_, err = columnsReadAndSum([]string{path("a.txt"), path("c.txt")}, formats[0], headerAuto, []string{"y"})
+	if want := path("c.txt") + ": unknown column y"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error %v, want one containing %q", err, want)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// commands maps the optional first argument of the binary to its handler.
+// Without an argument the binary runs the repetition tester as before.
+var commands = map[string]func(args []string) error{
+	"bound":      boundCommand,
+	"checkpoint": checkpointCommand,
+	"columns":    columnsCommand,
+	"compare":    compareCommand,
+	"convert":    convertCommand,
+	"corr":       correlationCommand,
+	"export":     exportCommand,
+	"filter":     filterCommand,
+	"follow":     followCommand,
+	"gate":       gateCommand,
+	"groupby":    groupByCommand,
+	"histogram":  histogramCommand,
+	"index":      indexCommand,
+	"measure":    measureCommand,
+	"multi":      multiCommand,
+	"quantiles":  quantilesCommand,
+	"range":      rangeCommand,
+	"sample":     sampleCommand,
+	"scale":      scaleCommand,
+	"stats":      statsCommand,
+	"sum":        sumCommand,
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// formatFlags are the -delimiter and -decimal flags of the commands that read
+// points files.
+type formatFlags struct {
+	delimiter, decimal *string
+}
+
+func addFormatFlags(flags *flag.FlagSet) formatFlags {
+	return formatFlags{
+		delimiter: flags.String("delimiter", "comma", "field delimiter: comma, tab, semicolon or pipe"),
+		decimal:   flags.String("decimal", "dot", "decimal separator: dot or comma"),
+	}
+}
+
+// format looks up the format named by the flags.
+func (ff formatFlags) format() (format, error) {
+	return findFormat(*ff.delimiter, *ff.decimal)
+}
+
+// inputFlags add -file, the points file a command reads, to the format flags.
+type inputFlags struct {
+	formatFlags
+	file *string
+}
+
+func addInputFlags(flags *flag.FlagSet, usage string) inputFlags {
+	return inputFlags{file: flags.String("file", "points.txt", usage), formatFlags: addFormatFlags(flags)}
+}
+
+func runCommand(name string, args []string) {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands: %v\n", name, names)
+		os.Exit(2)
+	}
+
+	if err := command(args); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resultKey tells apart the cold and warm results of a strategy.
+func (r runResult) resultKey() string {
+	if r.Cache == cacheCold {
+		return r.Strategy + " (cold)"
+	}
+	return r.Strategy
+}
+
+// loadResults reads a file of result records written with -output json or
+// -output csv. A strategy may have several records, e.g. one per new best
+// time of the repetition loop; the last one covers the most runs and wins.
+// Cold results are keyed "<strategy> (cold)". Records of another
+// schema_version than resultSchemaVersion are rejected.
+func loadResults(path string) (map[string]runResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("%s: no results", path)
+	}
+
+	results := make(map[string]runResult)
+	if first[0] == '{' {
+		dec := json.NewDecoder(reader)
+		for {
+			var r runResult
+			if err := dec.Decode(&r); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if r.SchemaVersion != resultSchemaVersion {
+				return nil, fmt.Errorf("%s: schema_version %d is not supported (want %d)", path, r.SchemaVersion, resultSchemaVersion)
+			}
+			results[r.resultKey()] = r
+		}
+	} else {
+		records, err := csv.NewReader(reader).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("%s: no results", path)
+		}
+		column := make(map[string]int)
+		for i, name := range records[0] {
+			column[name] = i
+		}
+		version, okVersion := column["schema_version"]
+		strategy, okStrategy := column["strategy"]
+		samples, okSamples := column["samples_ns"]
+		cache, okCache := column["cache"]
+		if !okVersion || !okStrategy || !okSamples {
+			return nil, fmt.Errorf("%s: CSV without schema_version, strategy and samples_ns columns", path)
+		}
+		for _, record := range records[1:] {
+			if record[version] != strconv.Itoa(resultSchemaVersion) {
+				return nil, fmt.Errorf("%s: schema_version %q is not supported (want %d)", path, record[version], resultSchemaVersion)
+			}
+			r := runResult{SchemaVersion: resultSchemaVersion, Strategy: record[strategy]}
+			if okCache {
+				r.Cache = record[cache]
+			}
+			for _, field := range strings.Fields(record[samples]) {
+				ns, err := strconv.ParseInt(field, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", path, err)
+				}
+				r.Timing.SamplesNs = append(r.Timing.SamplesNs, ns)
+			}
+			results[r.resultKey()] = r
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s: no results", path)
+	}
+	for name, r := range results {
+		if len(r.Timing.SamplesNs) == 0 {
+			return nil, fmt.Errorf("%s: %s has no samples_ns, it was written before they were recorded", path, name)
+		}
+	}
+	return results, nil
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U test of a
+// against b, i.e. the probability of a difference in ranks at least this big
+// if both come from the same distribution. It uses the exact distribution of
+// U for small samples without ties, and the normal approximation with tie and
+// continuity corrections otherwise.
+func mannWhitney(a, b []float64) float64 {
+	n1, n2 := len(a), len(b)
+	type value struct {
+		v     float64
+		fromA bool
+	}
+	all := make([]value, 0, n1+n2)
+	for _, v := range a {
+		all = append(all, value{v, true})
+	}
+	for _, v := range b {
+		all = append(all, value{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Rank sum of a, with tied values sharing their average rank.
+	var rankSumA, tieTerm float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromA {
+				rankSumA += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+	u := rankSumA - float64(n1*(n1+1))/2
+	mean := float64(n1*n2) / 2
+
+	if tieTerm == 0 && n1 <= 50 && n2 <= 50 {
+		// P(U <= min(u, n1*n2-u)), doubled.
+		counts := exactUCounts(n1, n2)
+		low := int(math.Min(u, float64(n1*n2)-u))
+		var total, tail float64
+		for k, c := range counts {
+			total += c
+			if k <= low {
+				tail += c
+			}
+		}
+		return math.Min(1, 2*tail/total)
+	}
+
+	n := float64(n1 + n2)
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance == 0 {
+		return 1
+	}
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	return math.Min(1, math.Erfc(math.Max(z, 0)/math.Sqrt2))
+}
+
+// exactUCounts returns how many of the orderings of n1+n2 distinct values
+// give each U from 0 to n1*n2, using c(n1, n2, u) = c(n1-1, n2, u-n2) +
+// c(n1, n2-1, u), depending on whether the largest value is from the first
+// sample or not.
+func exactUCounts(n1, n2 int) []float64 {
+	// prev[j] holds the counts for (i-1, j), cur[j] for (i, j).
+	prev := make([][]float64, n2+1)
+	for j := range prev {
+		prev[j] = []float64{1} // c(0, j, 0) = 1
+	}
+	for i := 1; i <= n1; i++ {
+		cur := make([][]float64, n2+1)
+		cur[0] = []float64{1} // c(i, 0, 0) = 1
+		for j := 1; j <= n2; j++ {
+			c := make([]float64, i*j+1)
+			for u := range c {
+				if u-j >= 0 && u-j < len(prev[j]) {
+					c[u] += prev[j][u-j]
+				}
+				if u < len(cur[j-1]) {
+					c[u] += cur[j-1][u]
+				}
+			}
+			cur[j] = c
+		}
+		prev = cur
+	}
+	return prev[n2]
+}
+
+func median(samples []int64) float64 {
+	sorted := append([]int64(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	m := float64(sorted[len(sorted)/2])
+	if len(sorted)%2 == 0 {
+		m = (float64(sorted[len(sorted)/2-1]) + m) / 2
+	}
+	return m
+}
+
+func toFloats(samples []int64) []float64 {
+	fs := make([]float64, len(samples))
+	for i, s := range samples {
+		fs[i] = float64(s)
+	}
+	return fs
+}
+
+// printOnlyIn lists the strategies of in that other does not have.
+func printOnlyIn(in, other map[string]runResult, path string) {
+	var missing []string
+	for name := range in {
+		if _, ok := other[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		fmt.Printf("%s: only in %s\n", name, path)
+	}
+}
+
+// compareCommand compares the timings of every strategy found in two result
+// files, like benchstat: the median before and after, the change and the
+// p-value of the Mann-Whitney U test. Changes with p below -alpha are
+// flagged as a regression or an improvement, the rest print ~.
+func compareCommand(args []string) error {
+	flags := newFlagSet("compare")
+	alpha := flags.Float64("alpha", 0.05, "significance level")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: parser compare [flags] old-results new-results")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("compare needs two result files, old and new")
+	}
+	old, err := loadResults(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	cur, err := loadResults(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range old {
+		if _, ok := cur[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return fmt.Errorf("the files have no strategy in common")
+	}
+
+	fmt.Printf("%-40s %12s %12s %9s %8s %7s\n", "strategy", "old", "new", "delta", "p", "n")
+	for _, name := range names {
+		a, b := old[name].Timing.SamplesNs, cur[name].Timing.SamplesNs
+		oldMedian, newMedian := median(a), median(b)
+		delta := 100 * (newMedian - oldMedian) / oldMedian
+		p := mannWhitney(toFloats(a), toFloats(b))
+
+		verdict := "~"
+		if p < *alpha {
+			verdict = "improvement"
+			if delta > 0 {
+				verdict = "regression"
+			}
+		}
+		fmt.Printf("%-40s %12v %12v %+8.2f%% %8.3f %7s  %s\n", name,
+			time.Duration(oldMedian).Round(time.Microsecond), time.Duration(newMedian).Round(time.Microsecond),
+			delta, p, fmt.Sprintf("%d+%d", len(a), len(b)), verdict)
+	}
+
+	printOnlyIn(old, cur, flags.Arg(0))
+	printOnlyIn(cur, old, flags.Arg(1))
+	return nil
+}
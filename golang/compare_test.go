@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// uSamples returns samples of sizes len(below) and 10 with U = sum(below),
+// where below[i] is how many values of the second sample are under the i-th
+// value of the first.
+func uSamples(below []int) (a, b []float64) {
+	for j := 0; j < 10; j++ {
+		b = append(b, float64(10*j))
+	}
+	for i, c := range below {
+		if c == 0 {
+			a = append(a, float64(-1-i))
+		} else {
+			a = append(a, float64(10*c-5))
+		}
+	}
+	return a, b
+}
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		p    float64
+	}{
+		// Exact distribution, p = 2 * P(U <= u) from the tables of U.
+		{"3+3 separated", []float64{1, 2, 3}, []float64{4, 5, 6}, 2.0 / 20},
+		{"4+4 separated", []float64{1, 2, 3, 4}, []float64{5, 6, 7, 8}, 2.0 / 70},
+		{"3+4 U=1", []float64{1, 2, 4}, []float64{3, 5, 6, 7}, 2 * 2.0 / 35},
+		{"5+5 U=2", []float64{1, 2, 3, 5, 6}, []float64{4, 7, 8, 9, 10}, 2 * 4.0 / 252},
+		{"5+5 reversed", []float64{4, 7, 8, 9, 10}, []float64{1, 2, 3, 5, 6}, 2 * 4.0 / 252},
+		{"identical ranks", []float64{1, 4, 5, 8}, []float64{2, 3, 6, 7}, 1},
+
+		// Normal approximation with tie and continuity corrections. Ranks
+		// 1, 2.5, 2.5, 5, 5, 8 give U = 3 against a mean of 21, the ties
+		// (2, 3, 3, 2, 2 values) a variance of 3.5 * (14 - 66/156) and
+		// z = 17.5 / sqrt(47.519...) = 2.5387.
+		{"ties", []float64{1, 2, 2, 3, 3, 4}, []float64{3, 4, 4, 5, 5, 6, 6}, 0.011128012415059976},
+		{"all tied", []float64{1, 1, 1}, []float64{1, 1}, 1},
+	}
+	for _, tt := range tests {
+		if p := mannWhitney(tt.a, tt.b); math.Abs(p-tt.p) > 1e-12 {
+			t.Errorf("%s: p = %v, want %v", tt.name, p, tt.p)
+		}
+	}
+
+	// The two-sided critical value of U for 10+10 at the 0.05 level is 23:
+	// U = 23 is significant and U = 24 is not.
+	if p := mannWhitney(uSamples([]int{0, 0, 0, 0, 0, 0, 5, 6, 6, 6})); p >= 0.05 {
+		t.Errorf("U = 23 for 10+10: p = %v, want below 0.05", p)
+	}
+	if p := mannWhitney(uSamples([]int{0, 0, 0, 0, 0, 0, 6, 6, 6, 6})); p < 0.05 {
+		t.Errorf("U = 24 for 10+10: p = %v, want at least 0.05", p)
+	}
+}
+
+func TestExactUCounts(t *testing.T) {
+	// The number of orderings giving each U for 3+3, from the table of U.
+	want := []float64{1, 1, 2, 3, 3, 3, 3, 2, 1, 1}
+	got := exactUCounts(3, 3)
+	if len(got) != len(want) {
+		t.Fatalf("exactUCounts(3, 3) = %v, want %v", got, want)
+	}
+	for u := range want {
+		if got[u] != want[u] {
+			t.Fatalf("exactUCounts(3, 3) = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string // Empty for a valid file
+	}{
+		{"json", `{"schema_version":1,"strategy":"a","cache":"warm","timing":{"samples_ns":[3,1,2]}}` + "\n" +
+			`{"schema_version":1,"strategy":"a","cache":"cold","timing":{"samples_ns":[5]}}`, ""},
+		{"csv", "schema_version,strategy,samples_ns,cache\n1,a,3 1 2,warm\n1,a,5,cold\n", ""},
+		{"empty", "", "no results"},
+		{"newline", "\n", "no results"},
+		{"csv header only", "schema_version,strategy,samples_ns,cache\n", "no results"},
+		{"json version 2", `{"schema_version":2,"strategy":"a","timing":{"samples_ns":[1]}}`, "schema_version 2 is not supported"},
+		{"json without version", `{"strategy":"a","timing":{"samples_ns":[1]}}`, "schema_version 0 is not supported"},
+		{"csv version 2", "schema_version,strategy,samples_ns\n2,a,1\n", `schema_version "2" is not supported`},
+		{"csv without version", "strategy,samples_ns\na,1\n", "without schema_version"},
+		{"csv bad sample", "schema_version,strategy,samples_ns\n1,a,1 x\n", "invalid syntax"},
+		{"no samples", "schema_version,strategy,samples_ns\n1,a,\n", "a has no samples_ns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			results, err := loadResults(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			warm, cold := results["a"], results["a (cold)"]
+			if len(results) != 2 || len(warm.Timing.SamplesNs) != 3 || len(cold.Timing.SamplesNs) != 1 || median(warm.Timing.SamplesNs) != 2 {
+				t.Errorf("loaded %+v", results)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// crossSums holds what the covariance, the Pearson correlation and a
+// least-squares fit of y on x need: the statistics of both columns and the
+// co-moment Σ(x-x̄)(y-ȳ). Like columnStats it is updated with Welford's
+// algorithm and partials are merged with the parallel variant, as the
+// textbook n*Σxy - Σx*Σy cancels badly once the sums of a large file grow.
+type crossSums struct {
+	x, y columnStats
+	cxy  float64 // Sum of the products of the differences from the means
+}
+
+func (c *crossSums) add(x, y float64) {
+	dx := x - c.x.mean
+	c.x.add(x)
+	c.y.add(y)
+	c.cxy += dx * (y - c.y.mean)
+}
+
+func (c *crossSums) merge(o crossSums) {
+	if o.x.count == 0 {
+		return
+	}
+	if c.x.count == 0 {
+		*c = o
+		return
+	}
+
+	n := float64(c.x.count + o.x.count)
+	dx, dy := o.x.mean-c.x.mean, o.y.mean-c.y.mean
+	c.cxy += o.cxy + dx*dy*float64(c.x.count)*float64(o.x.count)/n
+	c.x.merge(o.x)
+	c.y.merge(o.y)
+}
+
+// covariance is the population covariance of x and y.
+func (c crossSums) covariance() float64 {
+	return c.cxy / float64(c.x.count)
+}
+
+func (c crossSums) correlation() float64 {
+	return c.cxy / math.Sqrt(c.x.m2*c.y.m2)
+}
+
+// fit returns the least-squares line y = slope*x + intercept.
+func (c crossSums) fit() (slope, intercept float64) {
+	slope = c.cxy / c.x.m2
+	intercept = c.y.mean - slope*c.x.mean
+	return slope, intercept
+}
+
+// correlationReadAndSum computes the cross sums of both columns in one
+// parallel pass, one crossSums per worker.
+func correlationReadAndSum(filePath string, f format) (crossSums, error) {
+	results, err := readChunks(filePath, func(data []byte) crossSums {
+		var local crossSums
+		forEachPoint(data, f, local.add)
+		return local
+	})
+	if err != nil {
+		return crossSums{}, err
+	}
+
+	var total crossSums
+	for _, res := range results {
+		total.merge(res)
+	}
+	return total, nil
+}
+
+// correlationCommand prints the covariance, the Pearson correlation and the
+// regression line of y on x. With -expected it also prints what the
+// generator's y = x + rand*(max-x) should produce: for x uniform on [-a, a],
+// E[y|x] = (a+x)/2, so the slope is 1/2, the intercept a/2, the covariance
+// a²/6 and the correlation sqrt(3/7).
+func correlationCommand(args []string) error {
+	flags := newFlagSet("corr")
+	in := addInputFlags(flags, "file to parse")
+	expected := flags.Bool("expected", false, "also print the values the generator's distribution should give")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	res, err := correlationReadAndSum(*in.file, f)
+	if err != nil {
+		return err
+	}
+
+	slope, intercept := res.fit()
+	fmt.Printf("lines: %d\n", res.x.count)
+	fmt.Printf("covariance: %.6f\n", res.covariance())
+	fmt.Printf("correlation: %.6f\n", res.correlation())
+	fmt.Printf("fit: y = %.6f * x + %.6f\n", slope, intercept)
+
+	if *expected {
+		const a = 99.99
+		fmt.Println("generator expects:")
+		fmt.Printf("covariance: %.6f\n", a*a/6)
+		fmt.Printf("correlation: %.6f\n", math.Sqrt(3.0/7.0))
+		fmt.Printf("fit: y = %.6f * x + %.6f\n", 0.5, a/2)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// naiveCross computes the covariance, correlation and slope in two passes:
+// the means first, then the sums of the products of the differences.
+func naiveCross(xs, ys []float64) (cov, r, slope float64) {
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	n := float64(len(xs))
+	mx, my = mx/n, my/n
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	return sxy / n, sxy / math.Sqrt(sxx*syy), sxy / sxx
+}
+
+func closeTo(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance*math.Max(1, math.Abs(want))
+}
+
+func TestCorrelationReadAndSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	rng := rand.New(rand.NewSource(3))
+	var xs, ys []float64
+	var b strings.Builder
+	for i := 0; i < 20000; i++ {
+		// The generator's y = x + U*(a-x), rounded to two decimals.
+		x := math.Round((rng.Float64()*2-1)*9999) / 100
+		y := math.Round((x+rng.Float64()*(99.99-x))*100) / 100
+		fmt.Fprintf(&b, "%.2f,%.2f\n", x, y)
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := correlationReadAndSum(path, formats[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cov, r, slope := naiveCross(xs, ys)
+	gotSlope, intercept := res.fit()
+	if res.x.count != int64(len(xs)) {
+		t.Errorf("read %d lines, want %d", res.x.count, len(xs))
+	}
+	if !closeTo(res.covariance(), cov, 1e-9) || !closeTo(res.correlation(), r, 1e-9) || !closeTo(gotSlope, slope, 1e-9) {
+		t.Errorf("covariance, correlation, slope = %v, %v, %v, want %v, %v, %v",
+			res.covariance(), res.correlation(), gotSlope, cov, r, slope)
+	}
+	if want := res.y.mean - slope*res.x.mean; !closeTo(intercept, want, 1e-9) {
+		t.Errorf("intercept = %v, want %v", intercept, want)
+	}
+}
+
+// TestCrossSumsLargeOffset adds values far from zero, where n*Σxy - Σx*Σy
+// loses every significant digit, split between workers in uneven parts.
+func TestCrossSumsLargeOffset(t *testing.T) {
+	var xs, ys []float64
+	for i := 0; i < 100000; i++ {
+		d := float64(i%7) - 3
+		xs, ys = append(xs, 1e8+d), append(ys, 1e8-2*d+float64(i%3))
+	}
+	cov, r, slope := naiveCross(xs, ys)
+
+	for _, parts := range [][]int{{100000}, {1, 99999}, {33333, 33333, 33334}, {99999, 1}} {
+		var total crossSums
+		start := 0
+		for _, size := range parts {
+			var part crossSums
+			for i := start; i < start+size; i++ {
+				part.add(xs[i], ys[i])
+			}
+			total.merge(part)
+			start += size
+		}
+		gotSlope, _ := total.fit()
+		if !closeTo(total.covariance(), cov, 1e-6) || !closeTo(total.correlation(), r, 1e-6) || !closeTo(gotSlope, slope, 1e-6) {
+			t.Errorf("parts %v: covariance, correlation, slope = %v, %v, %v, want %v, %v, %v",
+				parts, total.covariance(), total.correlation(), gotSlope, cov, r, slope)
+		}
+	}
+}
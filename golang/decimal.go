@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// pow10 holds the powers of ten that are exactly representable as float64.
+var pow10 = [...]float64{
+	1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11,
+	1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22,
+}
+
+// parseDecimal parses a number such as -12.34, 12345.678901, 1e-3 or NaN
+// written with point as its decimal separator. The result is always the one
+// strconv.ParseFloat would return. ok is false when b is not a number.
+func parseDecimal(b []byte, point byte) (float64, bool) {
+	if f, ok := parseDecimalFast(b, point); ok {
+		return f, true
+	}
+
+	s := string(b)
+	if point != '.' {
+		if strings.IndexByte(s, '.') != -1 {
+			return 0, false // '.' is not a decimal point in this format
+		}
+		s = strings.Replace(s, string(point), ".", 1)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	return f, true
+}
+
+// parseDecimalFast handles the common case without allocating: at most 19
+// significant digits forming a mantissa below 2^53, scaled by a power of ten
+// within ±22. Both operands are then exact float64 values, so the single
+// multiplication or division rounds correctly (Clinger's fast path).
+// ok is false for anything it cannot convert exactly, including invalid input.
+func parseDecimalFast(b []byte, point byte) (float64, bool) {
+	i := 0
+	neg := false
+	if i < len(b) && (b[i] == '-' || b[i] == '+') {
+		neg = b[i] == '-'
+		i++
+	}
+
+	var mantissa uint64
+	digits, exp := 0, 0
+	sawDigits := false
+
+	for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+		sawDigits = true
+		if mantissa == 0 && b[i] == '0' {
+			continue // Leading zero
+		}
+		if digits == 19 {
+			return 0, false
+		}
+		mantissa = mantissa*10 + uint64(b[i]-'0')
+		digits++
+	}
+
+	if i < len(b) && b[i] == point {
+		i++
+		for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+			sawDigits = true
+			exp--
+			if mantissa == 0 && b[i] == '0' {
+				continue
+			}
+			if digits == 19 {
+				return 0, false
+			}
+			mantissa = mantissa*10 + uint64(b[i]-'0')
+			digits++
+		}
+	}
+
+	if !sawDigits {
+		return 0, false // NaN, Inf or not a number at all
+	}
+
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		expNeg := false
+		if i < len(b) && (b[i] == '-' || b[i] == '+') {
+			expNeg = b[i] == '-'
+			i++
+		}
+		if i == len(b) {
+			return 0, false
+		}
+		e := 0
+		for ; i < len(b) && b[i] >= '0' && b[i] <= '9'; i++ {
+			if e < 10000 {
+				e = e*10 + int(b[i]-'0')
+			}
+		}
+		if expNeg {
+			e = -e
+		}
+		exp += e
+	}
+
+	if i != len(b) || mantissa > 1<<53 {
+		return 0, false
+	}
+
+	f := float64(mantissa)
+	switch {
+	case mantissa == 0 || exp == 0:
+	case exp > 0 && exp <= 22:
+		f *= pow10[exp]
+	case exp < 0 && exp >= -22:
+		f /= pow10[-exp]
+	default:
+		return 0, false
+	}
+
+	if neg {
+		f = -f
+	}
+	return f, true
+}
+
+// parseFixed2 parses a number with exactly two decimals, such as -12.34, into
+// hundredths. Summing hundredths as integers is both faster and exact.
+// ok is false for anything else, e.g. 1.5, 12.345 or 1e3, and for more than
+// 7 integer digits so that billions of values still sum without overflow.
+func parseFixed2(b []byte, point byte) (int64, bool) {
+	n := len(b)
+	if n < 4 || b[n-3] != point {
+		return 0, false
+	}
+
+	start := 0
+	if b[0] == '-' || b[0] == '+' {
+		start = 1
+	}
+	if intDigits := n - 3 - start; intDigits < 1 || intDigits > 7 {
+		return 0, false
+	}
+
+	var v int64
+	for _, c := range b[start : n-3] {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		v = v*10 + int64(c-'0')
+	}
+
+	d1, d2 := b[n-2]-'0', b[n-1]-'0'
+	if d1 > 9 || d2 > 9 {
+		return 0, false
+	}
+	v = v*100 + int64(d1)*10 + int64(d2)
+
+	if b[0] == '-' {
+		v = -v
+	}
+	return v, true
+}
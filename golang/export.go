@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// The export command writes the parsed points column by column, as int16
+// hundredths like the binary format, in one of two layouts:
+//
+// raw: one headerless file per column, <out>.x.col and <out>.y.col, so a tool
+// that needs one column reads only that one.
+//
+// chunked: a single <out>.cols file made of chunks, each holding all x then
+// all y values of a run of lines, followed by a footer that describes every
+// chunk. The footer has the min, max and sum of both columns per chunk, so a
+// query can read it alone and skip the chunks that cannot match. Layout, all
+// little-endian:
+//
+//	chunk 0, chunk 1, ...        rows*int16 of x, then rows*int16 of y
+//	columnarChunkInfo * chunks
+//	columnarFooter               last 16 bytes of the file
+const columnarVersion = 1
+
+var columnarMagic = [4]byte{'P', 'T', 'S', 'C'}
+
+type columnarStats struct {
+	Min, Max int16
+	_        [4]byte
+	Sum      int64
+}
+
+type columnarChunkInfo struct {
+	Offset int64
+	Rows   int64
+	X, Y   columnarStats
+}
+
+type columnarFooter struct {
+	Chunks  uint32
+	Version uint16
+	Columns uint16
+	_       [4]byte
+	Magic   [4]byte
+}
+
+// columnWriter receives the parsed chunks in line order.
+type columnWriter interface {
+	writeChunk(xs, ys []int16) error
+	close() error
+}
+
+func putInt16s(w *bufio.Writer, values []int16) error {
+	var b [2]byte
+	for _, v := range values {
+		binary.LittleEndian.PutUint16(b[:], uint16(v))
+		if _, err := w.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rawColumnWriter struct {
+	files   [2]*os.File
+	writers [2]*bufio.Writer
+}
+
+func newRawColumnWriter(out string) (*rawColumnWriter, error) {
+	w := &rawColumnWriter{}
+	for i, name := range []string{out + ".x.col", out + ".y.col"} {
+		file, err := os.Create(name)
+		if err != nil {
+			w.close()
+			return nil, err
+		}
+		w.files[i] = file
+		w.writers[i] = bufio.NewWriterSize(file, 1<<20)
+	}
+	return w, nil
+}
+
+func (w *rawColumnWriter) writeChunk(xs, ys []int16) error {
+	if err := putInt16s(w.writers[0], xs); err != nil {
+		return err
+	}
+	return putInt16s(w.writers[1], ys)
+}
+
+func (w *rawColumnWriter) close() error {
+	var firstErr error
+	for i, file := range w.files {
+		if file == nil {
+			continue
+		}
+		if err := w.writers[i].Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type chunkedColumnWriter struct {
+	file   *os.File
+	w      *bufio.Writer
+	offset int64
+	chunks []columnarChunkInfo
+}
+
+func newChunkedColumnWriter(out string) (*chunkedColumnWriter, error) {
+	file, err := os.Create(out + ".cols")
+	if err != nil {
+		return nil, err
+	}
+	return &chunkedColumnWriter{file: file, w: bufio.NewWriterSize(file, 1<<20)}, nil
+}
+
+func statsOf(values []int16) columnarStats {
+	s := columnarStats{Min: math.MaxInt16, Max: math.MinInt16}
+	for _, v := range values {
+		s.Min = min(s.Min, v)
+		s.Max = max(s.Max, v)
+		s.Sum += int64(v)
+	}
+	return s
+}
+
+func (w *chunkedColumnWriter) writeChunk(xs, ys []int16) error {
+	if len(xs) == 0 {
+		return nil
+	}
+	w.chunks = append(w.chunks, columnarChunkInfo{
+		Offset: w.offset,
+		Rows:   int64(len(xs)),
+		X:      statsOf(xs),
+		Y:      statsOf(ys),
+	})
+	if err := putInt16s(w.w, xs); err != nil {
+		return err
+	}
+	if err := putInt16s(w.w, ys); err != nil {
+		return err
+	}
+	w.offset += 4 * int64(len(xs))
+	return nil
+}
+
+func (w *chunkedColumnWriter) close() error {
+	footer := columnarFooter{Chunks: uint32(len(w.chunks)), Version: columnarVersion, Columns: 2, Magic: columnarMagic}
+	err := binary.Write(w.w, binary.LittleEndian, w.chunks)
+	if err == nil {
+		err = binary.Write(w.w, binary.LittleEndian, footer)
+	}
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// parseHundredthsChunk parses the lines of a chunk into hundredths. In the
+// first chunk of the file a first line that does not parse is taken for a
+// header and skipped, like the convert command does.
+func parseHundredthsChunk(data []byte, offset int64, f format, first bool) (xs, ys []int16, err error) {
+	lineStart := 0
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && data[i] != '\n' {
+			continue
+		}
+		line := trimLine(data[lineStart:i])
+		if len(trimField(line)) > 0 {
+			a, b, ok := f.split(line)
+			var x, y int16
+			if ok {
+				var okX, okY bool
+				x, okX = toHundredths(a, f.decimal)
+				y, okY = toHundredths(b, f.decimal)
+				ok = okX && okY
+			}
+			switch {
+			case ok:
+				xs = append(xs, x)
+				ys = append(ys, y)
+			case first && lineStart == 0:
+			default:
+				return nil, nil, fmt.Errorf("line at offset %d: %q is not two values with at most two decimals in -327.68..327.67",
+					offset+int64(lineStart), line)
+			}
+		}
+		lineStart = i + 1
+	}
+	return xs, ys, nil
+}
+
+// parseExportChunk parses a chunk for exportColumns. Tests replace it to make
+// chunks finish out of order.
+var parseExportChunk = parseHundredthsChunk
+
+// exportColumns parses the file in chunks with numWorkers goroutines and hands
+// the chunks to w in line order. Finished chunks that arrive early wait in
+// pending until the ones before them are written; at most 2*numWorkers chunks
+// are parsed or waiting at any time, which bounds the memory used.
+func exportColumns(filePath string, f format, w columnWriter, chunkSize int64, numWorkers int) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	numChunks := int(max(1, (stat.Size()+chunkSize-1)/chunkSize))
+	chunks, err := splitChunks(file, 0, stat.Size(), numChunks)
+	if err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		index  int
+		xs, ys []int16
+		err    error
+	}
+	jobs := make(chan int)
+	results := make(chan result, numWorkers)
+	tokens := make(chan struct{}, 2*numWorkers)
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+		for i := range chunks {
+			select {
+			case tokens <- struct{}{}:
+			case <-stop:
+				return
+			}
+			jobs <- i
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				c := chunks[index]
+				data := make([]byte, c.size)
+				if _, err := file.ReadAt(data, c.offset); err != nil && err != io.EOF {
+					results <- result{index: index, err: err}
+					continue
+				}
+				xs, ys, err := parseExportChunk(data, c.offset, f, index == 0)
+				results <- result{index: index, xs: xs, ys: ys, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]result)
+	next := 0
+	var rows int64
+	for res := range results {
+		if err != nil {
+			continue // Drain the workers
+		}
+		if res.err != nil {
+			err = res.err
+			close(stop)
+			continue
+		}
+		pending[res.index] = res
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if err = w.writeChunk(ready.xs, ready.ys); err != nil {
+				close(stop)
+				break
+			}
+			rows += int64(len(ready.xs))
+			next++
+			<-tokens
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
+// exportCommand writes the columns of a points file as fixed-point files.
+func exportCommand(args []string) error {
+	flags := newFlagSet("export")
+	in := addInputFlags(flags, "file to parse")
+	out := flags.String("out", "points", "output name; raw writes <out>.x.col and <out>.y.col, chunked writes <out>.cols")
+	layout := flags.String("layout", "raw", "raw for one file per column, chunked for one file with per-chunk statistics")
+	chunkSize := flags.Int64("chunk-size", 16<<20, "bytes of text per chunk")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *chunkSize < 1 {
+		return fmt.Errorf("-chunk-size must be positive")
+	}
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	var w columnWriter
+	switch *layout {
+	case "raw":
+		w, err = newRawColumnWriter(*out)
+	case "chunked":
+		w, err = newChunkedColumnWriter(*out)
+	default:
+		return fmt.Errorf("unknown layout %q (want raw or chunked)", *layout)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := exportColumns(*in.file, f, w, *chunkSize, runtime.NumCPU())
+	if closeErr := w.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d rows (%s)\n", rows, *layout)
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingWriter keeps the chunks it is given, in order.
+type recordingWriter struct {
+	xs, ys [][]int16
+}
+
+func (w *recordingWriter) writeChunk(xs, ys []int16) error {
+	w.xs = append(w.xs, xs)
+	w.ys = append(w.ys, ys)
+	return nil
+}
+
+func (w *recordingWriter) close() error { return nil }
+
+// hundredthsOf converts values with two decimals to int16 hundredths.
+func hundredthsOf(values []float64) []int16 {
+	out := make([]int16, len(values))
+	for i, v := range values {
+		out[i] = int16(math.Round(v * 100))
+	}
+	return out
+}
+
+func readInt16s(t *testing.T, data []byte) []int16 {
+	t.Helper()
+	values := make([]int16, len(data)/2)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, values); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func equalInt16s(a, b []int16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExportColumnsOutOfOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	xs, ys := writePoints(t, path, 2000)
+
+	// The first chunk finishes last, so every other one waits in pending.
+	var mu sync.Mutex
+	var finished []int64
+	parseExportChunk = func(data []byte, offset int64, f format, first bool) ([]int16, []int16, error) {
+		if first {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		finished = append(finished, offset)
+		mu.Unlock()
+		return parseHundredthsChunk(data, offset, f, first)
+	}
+	defer func() { parseExportChunk = parseHundredthsChunk }()
+
+	var w recordingWriter
+	rows, err := exportColumns(path, formats[0], &w, 1024, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(finished) < 2 || finished[0] == 0 {
+		t.Fatalf("chunks finished at offsets %v, want the first one late", finished)
+	}
+	if rows != int64(len(xs)) || len(w.xs) != len(finished) {
+		t.Fatalf("exported %d rows in %d chunks, want %d rows in %d", rows, len(w.xs), len(xs), len(finished))
+	}
+	var gotX, gotY []int16
+	for i := range w.xs {
+		gotX, gotY = append(gotX, w.xs[i]...), append(gotY, w.ys[i]...)
+	}
+	if !equalInt16s(gotX, hundredthsOf(xs)) || !equalInt16s(gotY, hundredthsOf(ys)) {
+		t.Error("the chunks were not written in line order")
+	}
+}
+
+func TestExportColumnsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	var b strings.Builder
+	var badOffset int
+	for i := 0; i < 2000; i++ {
+		if i == 1000 {
+			badOffset = b.Len()
+			b.WriteString("1.005,2.00\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%d.25,-%d.50\n", i%100, i%37)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		var w recordingWriter
+		rows, err := exportColumns(path, formats[0], &w, 1024, workers)
+		want := fmt.Sprintf("line at offset %d:", badOffset)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%d workers: error %v, want one containing %q", workers, err, want)
+		}
+		var written int
+		for _, chunk := range w.xs {
+			written += len(chunk)
+		}
+		if rows != 0 || written > 1000 {
+			t.Errorf("%d workers: returned %d rows and wrote %d, want 0 returned and none after line 1000", workers, rows, written)
+		}
+	}
+}
+
+func TestExportRaw(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "points.txt")
+	xs, ys := writePoints(t, path, 3000)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append([]byte("x,y\n"), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "points")
+	w, err := newRawColumnWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := exportColumns(path, formats[0], w, 4096, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	if rows != int64(len(xs)) {
+		t.Errorf("exported %d rows, want %d", rows, len(xs))
+	}
+
+	for _, c := range []struct {
+		suffix string
+		want   []int16
+	}{{".x.col", hundredthsOf(xs)}, {".y.col", hundredthsOf(ys)}} {
+		data, err := os.ReadFile(out + c.suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalInt16s(readInt16s(t, data), c.want) {
+			t.Errorf("%s does not hold the column", c.suffix)
+		}
+	}
+}
+
+func TestExportChunked(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "points.txt")
+	xs, ys := writePoints(t, path, 3000)
+
+	out := filepath.Join(dir, "points")
+	w, err := newChunkedColumnWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exportColumns(path, formats[0], w, 4096, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out + ".cols")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	footerSize := binary.Size(columnarFooter{})
+	var footer columnarFooter
+	if err := binary.Read(bytes.NewReader(data[len(data)-footerSize:]), binary.LittleEndian, &footer); err != nil {
+		t.Fatal(err)
+	}
+	if footer.Magic != columnarMagic || footer.Version != columnarVersion || footer.Columns != 2 || footer.Chunks < 2 {
+		t.Fatalf("footer %+v", footer)
+	}
+	infos := make([]columnarChunkInfo, footer.Chunks)
+	infoStart := len(data) - footerSize - binary.Size(infos)
+	if err := binary.Read(bytes.NewReader(data[infoStart:]), binary.LittleEndian, infos); err != nil {
+		t.Fatal(err)
+	}
+
+	wantX, wantY := hundredthsOf(xs), hundredthsOf(ys)
+	var offset, row int64
+	for i, info := range infos {
+		if info.Offset != offset || info.Rows <= 0 {
+			t.Fatalf("chunk %d is %+v, want it at offset %d", i, info, offset)
+		}
+		chunkX := readInt16s(t, data[offset:offset+2*info.Rows])
+		chunkY := readInt16s(t, data[offset+2*info.Rows:offset+4*info.Rows])
+		if !equalInt16s(chunkX, wantX[row:row+info.Rows]) || !equalInt16s(chunkY, wantY[row:row+info.Rows]) {
+			t.Errorf("chunk %d does not hold rows %d to %d", i, row, row+info.Rows)
+		}
+		if info.X != statsOf(chunkX) || info.Y != statsOf(chunkY) {
+			t.Errorf("chunk %d has stats %+v, %+v, want %+v, %+v", i, info.X, info.Y, statsOf(chunkX), statsOf(chunkY))
+		}
+		offset += 4 * info.Rows
+		row += info.Rows
+	}
+	if row != int64(len(xs)) || offset != int64(infoStart) {
+		t.Errorf("chunks hold %d rows and end at %d, want %d rows ending at %d", row, offset, len(xs), infoStart)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// filterCases are filters and the same conditions written in Go.
+var filterCases = []struct {
+	src  string
+	want func(x, y float64) bool
+}{
+	// Precedence
+	{"x > 0 or y > 0 and x < -5", func(x, y float64) bool { return x > 0 || y > 0 && x < -5 }},
+	{"(x > 0 or y > 0) and x < -5", func(x, y float64) bool { return (x > 0 || y > 0) && x < -5 }},
+	{"x + y * 2 > 10", func(x, y float64) bool { return x+y*2 > 10 }},
+	{"(x + y) * 2 > 10", func(x, y float64) bool { return (x+y)*2 > 10 }},
+	{"x - y - 1 < 0", func(x, y float64) bool { return x-y-1 < 0 }},
+	{"x / 2 / 2 >= y", func(x, y float64) bool { return x/2/2 >= y }},
+	{"x > 0 and y > 0 or x < 0 and y < 0", func(x, y float64) bool { return x > 0 && y > 0 || x < 0 && y < 0 }},
+	{"X >= 1E1 AND y != 3", func(x, y float64) bool { return x >= 10 && y != 3 }},
+
+	// between, inclusive on both ends
+	{"x between -10 and 10", func(x, y float64) bool { return x >= -10 && x <= 10 }},
+	{"y between x - 1 and x + 1", func(x, y float64) bool { return y >= x-1 && y <= x+1 }},
+	{"x between 0 and 5 and y between 0 and 5", func(x, y float64) bool { return x >= 0 && x <= 5 && y >= 0 && y <= 5 }},
+	{"x between 5 and 0", func(x, y float64) bool { return false }},
+
+	// Negation
+	{"not x > 0", func(x, y float64) bool { return !(x > 0) }},
+	{"not not x > 0", func(x, y float64) bool { return x > 0 }},
+	{"not x > 0 and y > 0", func(x, y float64) bool { return !(x > 0) && y > 0 }},
+	{"not (x > 0 or y == 0)", func(x, y float64) bool { return !(x > 0 || y == 0) }},
+	{"x > 0 and not (y < x * 2 or y = 0)", func(x, y float64) bool { return x > 0 && !(y < x*2 || y == 0) }},
+	{"-x > 3", func(x, y float64) bool { return -x > 3 }},
+	{"- -x <= -y", func(x, y float64) bool { return x <= -y }},
+}
+
+func TestCompileFilter(t *testing.T) {
+	for _, tt := range filterCases {
+		keep, err := compileFilter(tt.src)
+		if err != nil {
+			t.Errorf("compileFilter(%q): %v", tt.src, err)
+			continue
+		}
+		for x := -12.0; x <= 12; x += 0.5 {
+			for y := -12.0; y <= 12; y += 0.5 {
+				if got := keep(x, y); got != tt.want(x, y) {
+					t.Fatalf("%q at x=%v, y=%v = %v, want %v", tt.src, x, y, got, !got)
+				}
+			}
+		}
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"", "column 1: unexpected end of filter"},
+		{"x >", "column 4: unexpected end of filter"},
+		{"x > 0 y", `column 7: unexpected "y" in filter`},
+		{"x > 0 & y > 0", `column 7: unexpected character '&' in filter`},
+		{"x > 0 and 3", "column 7: and needs conditions on both sides"},
+		{"1 or x > 0", "column 3: or needs conditions on both sides"},
+		{"not x", "column 1: not needs a condition"},
+		{"x > 0 and not 2", "column 11: not needs a condition"},
+		{"(x > 0) < 1", "column 9: < needs numbers on both sides"},
+		{"x == (y > 1)", "column 3: == needs numbers on both sides"},
+		{"x + (y > 1) > 0", "column 3: + needs numbers on both sides"},
+		{"x * (y > 1) > 0", "column 3: * needs numbers on both sides"},
+		{"-(x > 0)", "column 1: - needs a number"},
+		{"x between 1 or 2", `column 13: between needs the form: value between low and high`},
+		{"x between 1", "column 12: between needs the form: value between low and high"},
+		{"x between (y > 0) and 1", "column 3: between needs numbers"},
+		{"(x > 0", "column 7: missing ) for the ( at column 1"},
+		{"x > 1.2.3", `column 5: unexpected "1.2.3" in filter`},
+		{"x > z", `column 5: unexpected "z" in filter`},
+		{"x + 1", `filter "x + 1" is a number, not a condition`},
+	}
+	for _, tt := range tests {
+		if _, err := compileFilter(tt.src); err == nil || err.Error() != tt.err {
+			t.Errorf("compileFilter(%q) = %v, want %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestFilterReadAndSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	var b strings.Builder
+	var xs, ys []float64
+	for i := 0; i < 20000; i++ {
+		x, y := float64(i*7919%2001-1000)/100, float64(i*104729%2001-1000)/100
+		fmt.Fprintf(&b, "%.2f,%.2f\n", x, y)
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range filterCases {
+		keep, err := compileFilter(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched, lines, err := filterReadAndSum(path, formats[0], keep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var count int64
+		var sumX, sumY float64
+		for i := range xs {
+			if tt.want(xs[i], ys[i]) {
+				count++
+				sumX += xs[i]
+				sumY += ys[i]
+			}
+		}
+		if lines != int64(len(xs)) || matched.x.count != count {
+			t.Errorf("%q matched %d of %d lines, want %d of %d", tt.src, matched.x.count, lines, count, len(xs))
+		}
+		if math.Abs(matched.x.sum-sumX) > 1e-6 || math.Abs(matched.y.sum-sumY) > 1e-6 {
+			t.Errorf("%q sums = %v, %v, want %v, %v", tt.src, matched.x.sum, matched.y.sum, sumX, sumY)
+		}
+	}
+}
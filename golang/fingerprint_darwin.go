@@ -0,0 +1,13 @@
+//go:build darwin
+
+package main
+
+import "syscall"
+
+func cpuModel() string {
+	model, err := syscall.Sysctl("machdep.cpu.brand_string")
+	if err != nil {
+		return "unknown"
+	}
+	return model
+}
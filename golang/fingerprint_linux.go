@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// cpuModel returns the "model name" of /proc/cpuinfo, or its "Hardware" line
+// on ARM boards that have no model name.
+func cpuModel() string {
+	file, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return "unknown"
+	}
+	defer file.Close()
+
+	hardware := "unknown"
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "model name":
+			return strings.TrimSpace(value)
+		case "Hardware":
+			hardware = strings.TrimSpace(value)
+		}
+	}
+	return hardware
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package main
+
+func cpuModel() string {
+	return "unknown"
+}
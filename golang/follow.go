@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// watcher blocks until the followed file may have changed or timeout passes,
+// whichever comes first. Spurious wake-ups are fine: the follower only ever
+// acts on what it finds in the file afterwards.
+type watcher interface {
+	wait(timeout time.Duration) error
+	close() error
+}
+
+// pollWatcher just sleeps; the follower then looks at the file again.
+type pollWatcher struct {
+	interval time.Duration
+}
+
+func (w pollWatcher) wait(timeout time.Duration) error {
+	time.Sleep(min(w.interval, timeout))
+	return nil
+}
+
+func (w pollWatcher) close() error { return nil }
+
+// follower keeps running statistics of a file that is being appended to.
+// It remembers the offset it has read up to and the unfinished line at the
+// end, so every byte is parsed once no matter how the appends are split.
+// Totals carry over rotation and truncation: they describe everything that
+// was ever read under the path.
+type follower struct {
+	path    string
+	f       format
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+	buffer  []byte
+	totals  pointStats
+	changed bool
+}
+
+func newFollower(path string, f format, fromEnd bool) (*follower, error) {
+	t := &follower{path: path, f: f, buffer: make([]byte, 1<<20)}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	if fromEnd && t.file != nil {
+		t.offset = t.info.Size()
+		// Start after the last complete line, not in the middle of one.
+		for t.offset > 0 {
+			var b [1]byte
+			if _, err := t.file.ReadAt(b[:], t.offset-1); err != nil {
+				return nil, err
+			}
+			if b[0] == '\n' {
+				break
+			}
+			t.offset--
+		}
+	}
+	return t, nil
+}
+
+// open opens the file under the path. A missing file is not an error: it may
+// be about to be created by the producer.
+func (t *follower) open() error {
+	file, err := os.Open(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	t.file, t.info, t.offset, t.partial = file, info, 0, t.partial[:0]
+	return nil
+}
+
+// update reads whatever was appended since the last call. It notices when
+// the file shrank below the offset (truncated, read again from the start) and
+// when the path now names another file (rotated, the old one is read to its
+// end first).
+func (t *follower) update() error {
+	if t.file == nil {
+		if err := t.open(); err != nil || t.file == nil {
+			return err
+		}
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < t.offset {
+		fmt.Fprintf(os.Stderr, "%s was truncated, reading it from the start\n", t.path)
+		t.offset, t.partial = 0, t.partial[:0]
+	}
+	if err := t.readToEnd(); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(t.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil && os.SameFile(info, current) {
+		return nil
+	}
+
+	// The path was renamed away or removed. Lines may have been appended to
+	// the old file since it was read above, and it will not grow any more,
+	// so its last line counts even without a '\n'.
+	if err := t.readToEnd(); err != nil {
+		return err
+	}
+	t.line(t.partial)
+	t.file.Close()
+	t.file, t.partial = nil, t.partial[:0]
+	fmt.Fprintf(os.Stderr, "%s was rotated, following the new file\n", t.path)
+	if err := t.open(); err != nil || t.file == nil {
+		return err
+	}
+	return t.readToEnd()
+}
+
+func (t *follower) readToEnd() error {
+	for {
+		n, err := t.file.ReadAt(t.buffer, t.offset)
+		if n > 0 {
+			t.offset += int64(n)
+			t.consume(t.buffer[:n])
+		}
+		if err == io.EOF || n == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// consume parses the complete lines of data, preceded by the partial line
+// kept from the previous read, and keeps the new partial line.
+func (t *follower) consume(data []byte) {
+	last := bytes.LastIndexByte(data, '\n')
+	if last == -1 {
+		t.partial = append(t.partial, data...)
+		return
+	}
+
+	lineStart := 0
+	if len(t.partial) > 0 {
+		first := bytes.IndexByte(data, '\n')
+		t.partial = append(t.partial, data[:first]...)
+		t.line(t.partial)
+		lineStart = first + 1
+	}
+	for i := lineStart; i <= last; i++ {
+		if data[i] == '\n' {
+			t.line(data[lineStart:i])
+			lineStart = i + 1
+		}
+	}
+	t.partial = append(t.partial[:0], data[last+1:]...)
+}
+
+func (t *follower) line(line []byte) {
+	if a, b, ok := t.f.split(line); ok {
+		x, okX := parseDecimal(a, t.f.decimal)
+		y, okY := parseDecimal(b, t.f.decimal)
+		if okX && okY {
+			t.totals.x.add(x)
+			t.totals.y.add(y)
+			t.changed = true
+		}
+	}
+}
+
+func (t *follower) print() {
+	fmt.Printf("%s lines %d x avg %.6f y avg %.6f\n",
+		time.Now().Format("15:04:05"), t.totals.x.count, t.totals.x.mean, t.totals.y.mean)
+	t.changed = false
+}
+
+// followCommand prints the running averages of a file that keeps growing,
+// every -interval when new lines arrived, until interrupted.
+func followCommand(args []string) error {
+	flags := newFlagSet("follow")
+	in := addInputFlags(flags, "file to follow")
+	interval := flags.Duration("interval", time.Second, "how often to print the updated averages")
+	watch := flags.String("watch", "auto", "how to notice changes: auto, inotify or poll")
+	poll := flags.Duration("poll", 250*time.Millisecond, "how often to look at the file when polling")
+	fromEnd := flags.Bool("from-end", false, "skip the lines already in the file")
+	duration := flags.Duration("duration", 0, "stop after this long (0 runs until interrupted)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *interval <= 0 || *poll <= 0 {
+		return fmt.Errorf("-interval and -poll must be positive")
+	}
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	var w watcher
+	switch *watch {
+	case "poll":
+		w = pollWatcher{interval: *poll}
+	case "inotify", "auto":
+		w, err = newInotifyWatcher(*in.file)
+		if err != nil {
+			if *watch == "inotify" {
+				return err
+			}
+			w = pollWatcher{interval: *poll}
+		}
+	default:
+		return fmt.Errorf("unknown watch mode %q (want auto, inotify or poll)", *watch)
+	}
+	defer w.close()
+
+	t, err := newFollower(*in.file, f, *fromEnd)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	start := time.Now()
+	nextPrint := start
+	for {
+		if err := t.update(); err != nil {
+			return err
+		}
+		now := time.Now()
+		if !now.Before(nextPrint) {
+			if t.changed {
+				t.print()
+			}
+			nextPrint = now.Add(*interval)
+		}
+
+		select {
+		case <-interrupt:
+			t.print()
+			return nil
+		default:
+		}
+		if *duration > 0 && now.Sub(start) >= *duration {
+			t.print()
+			return nil
+		}
+
+		// Wake up at least every -poll so an interrupt is noticed promptly.
+		// A print that is already due still waits a millisecond, as the
+		// inotify watcher would block for good on a negative timeout and
+		// spin on one that rounds down to zero.
+		if err := w.wait(max(min(time.Until(nextPrint), *poll), time.Millisecond)); err != nil {
+			return err
+		}
+	}
+}
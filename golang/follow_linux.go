@@ -0,0 +1,65 @@
+//go:build linux
+
+package main
+
+import (
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// inotifyWatcher wakes up on changes to any file in the directory of the
+// followed one. Watching the directory rather than the file keeps working
+// across rotation, when the path starts to name a new file.
+type inotifyWatcher struct {
+	fd, epfd int
+	buffer   []byte
+}
+
+func newInotifyWatcher(path string) (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	const mask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		syscall.Close(epfd)
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &inotifyWatcher{fd: fd, epfd: epfd, buffer: make([]byte, 64*1024)}, nil
+}
+
+func (w *inotifyWatcher) wait(timeout time.Duration) error {
+	var events [1]syscall.EpollEvent
+	// Rounded up, as EpollWait takes milliseconds and -1 means forever.
+	msec := max(int((timeout+time.Millisecond-1)/time.Millisecond), 1)
+	_, err := syscall.EpollWait(w.epfd, events[:], msec)
+	if err != nil && err != syscall.EINTR {
+		return err
+	}
+	// Only the wake-up matters, the events themselves are dropped.
+	for {
+		n, err := syscall.Read(w.fd, w.buffer)
+		if n <= 0 || err != nil {
+			return nil
+		}
+	}
+}
+
+func (w *inotifyWatcher) close() error {
+	syscall.Close(w.epfd)
+	return syscall.Close(w.fd)
+}
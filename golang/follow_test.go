@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFollower changes the followed file step by step and checks the running
+// sums after every update.
+func TestFollower(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "points.txt")
+	fl, err := newFollower(path, formats[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if fl.file != nil {
+			fl.file.Close()
+		}
+	}()
+
+	steps := []struct {
+		name       string
+		change     func()
+		count      int64
+		sumX, sumY float64
+	}{
+		{"missing file", func() {}, 0, 0, 0},
+		{"created", func() { appendFile(t, path, "1.00,2.00\n") }, 1, 1, 2},
+		{"partial line", func() { appendFile(t, path, "3.00,4.") }, 1, 1, 2},
+		{"partial line completed", func() { appendFile(t, path, "50\n5.00,6.00\n") }, 3, 9, 12.5},
+		{"nothing new", func() {}, 3, 9, 12.5},
+		{"truncated below the offset", func() {
+			if err := os.WriteFile(path, []byte("10.00,20.00\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, 4, 19, 32.5},
+		{"appended after the truncation", func() { appendFile(t, path, "1.00,1.00\n") }, 5, 20, 33.5},
+		{"renamed and recreated", func() {
+			appendFile(t, path, "2.00,2.00\n3.00,") // Written before the rename
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			appendFile(t, path+".1", "3.00") // By a writer that still has it open
+			appendFile(t, path, "100.00,100.00\n7.00,")
+		}, 8, 125, 138.5},
+		{"new file's partial line completed", func() { appendFile(t, path, "7.00\n") }, 9, 132, 145.5},
+		{"old file grows no more", func() { appendFile(t, path+".1", "\n1000.00,1000.00\n") }, 9, 132, 145.5},
+	}
+	for _, step := range steps {
+		step.change()
+		if err := fl.update(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := fl.totals; got.x.count != step.count || got.x.sum != step.sumX || got.y.sum != step.sumY {
+			t.Fatalf("after %s: %d lines, sums %v, %v, want %d lines, sums %v, %v",
+				step.name, got.x.count, got.x.sum, got.y.sum, step.count, step.sumX, step.sumY)
+		}
+	}
+}
+
+func TestFollowerFromEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	appendFile(t, path, "1.00,2.00\n3.00,")
+	fl, err := newFollower(path, formats[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.file.Close()
+
+	appendFile(t, path, "4.00\n5.00,6.00\n")
+	if err := fl.update(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fl.totals; got.x.count != 2 || got.x.sum != 8 || got.y.sum != 10 {
+		t.Errorf("%d lines, sums %v, %v, want the 2 lines after the last complete one", got.x.count, got.x.sum, got.y.sum)
+	}
+}
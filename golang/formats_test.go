@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDecimalEuropean(t *testing.T) {
+	for s, want := range map[string]float64{"1,50": 1.5, "-2,25": -2.25, "7": 7, "1e3": 1000, "12345,678901": 12345.678901} {
+		if v, ok := parseDecimal([]byte(s), ','); !ok || v != want {
+			t.Errorf("parseDecimal(%q, ',') = %v, %v, want %v", s, v, ok, want)
+		}
+	}
+	for _, s := range []string{"1.50", "1.5", "1.000,50", "1,5.0", "12345.678901234567890", "1,2,3"} {
+		if v, ok := parseDecimal([]byte(s), ','); ok {
+			t.Errorf("parseDecimal(%q, ',') = %v, want not ok", s, v)
+		}
+	}
+}
+
+func TestFormatSumChunk(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string // Comma separated with dot decimals; rewritten for each format
+		x, y  float64
+		n     int64
+	}{
+		{"fixed", []string{"1.25,-2.50", "-0.75,3.00", "10.00,0.01"}, 10.5, 0.51, 3},
+		{"general", []string{"1.25,-2.50", "1.5,3", "1e1,0.001"}, 12.75, 0.501, 3},
+		{"skipped", []string{"x,y", "1.25,-2.50", "", "3.00", "1.00,abc", "0.75,0.50"}, 2, -2, 2},
+		{"messy", []string{"\xEF\xBB\xBF1.25, -2.50\r", " 0.75 ,\t0.50 "}, 2, -2, 2},
+	}
+	for _, f := range formats {
+		for _, tt := range tests {
+			data := strings.Join(tt.lines, "\n")
+			data = strings.NewReplacer(",", string(f.delimiter), ".", string(f.decimal)).Replace(data)
+			x, y, n := f.sumChunk([]byte(data))
+			if n != tt.n || !closeTo(x, tt.x, 1e-12) || !closeTo(y, tt.y, 1e-12) {
+				t.Errorf("%s %s: sumChunk = %v, %v, %d, want %v, %v, %d", f.name, tt.name, x, y, n, tt.x, tt.y, tt.n)
+			}
+		}
+	}
+
+	// A dot is not a decimal point in the european format.
+	if _, _, n := formats[4].sumChunk([]byte("1.50;2,00\n1,50;2,00")); n != 1 {
+		t.Errorf("european sumChunk accepted %d lines, want only the one with comma decimals", n)
+	}
+}
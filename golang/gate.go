@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// A baseline file holds the expected timings of strategies, one entry per
+// strategy and machine, so that a single committed file serves every
+// developer's laptop and the CI machines. The gate only compares against the
+// entry recorded on a machine with the same fingerprint.
+const baselineSchemaVersion = 1
+
+type machineFingerprint struct {
+	CPU    string `json:"cpu"`
+	Cores  int    `json:"cores"`
+	GOARCH string `json:"goarch"`
+}
+
+func currentFingerprint() machineFingerprint {
+	return machineFingerprint{CPU: cpuModel(), Cores: runtime.NumCPU(), GOARCH: runtime.GOARCH}
+}
+
+func (m machineFingerprint) String() string {
+	return fmt.Sprintf("%s, %d cores, %s", m.CPU, m.Cores, m.GOARCH)
+}
+
+type baselineEntry struct {
+	Machine   machineFingerprint `json:"machine"`
+	Strategy  string             `json:"strategy"`
+	FileBytes int64              `json:"file_bytes"`
+	Runs      int                `json:"runs"`
+	BestNs    int64              `json:"best_ns"`
+	MedianNs  int64              `json:"median_ns"`
+	// Median absolute deviation of the runs, the noise of the machine
+	MADNs    int64     `json:"mad_ns"`
+	Recorded time.Time `json:"recorded"`
+}
+
+type baselineFile struct {
+	SchemaVersion int             `json:"schema_version"`
+	Baselines     []baselineEntry `json:"baselines"`
+}
+
+func loadBaselines(path string) (baselineFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return baselineFile{SchemaVersion: baselineSchemaVersion}, nil
+	}
+	if err != nil {
+		return baselineFile{}, err
+	}
+
+	var b baselineFile
+	if err := json.Unmarshal(data, &b); err != nil {
+		return baselineFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if b.SchemaVersion != baselineSchemaVersion {
+		return baselineFile{}, fmt.Errorf("%s: baseline schema version %d is not supported (want %d)", path, b.SchemaVersion, baselineSchemaVersion)
+	}
+	return b, nil
+}
+
+func (b *baselineFile) find(m machineFingerprint, strategy string) *baselineEntry {
+	for i := range b.Baselines {
+		if b.Baselines[i].Machine == m && b.Baselines[i].Strategy == strategy {
+			return &b.Baselines[i]
+		}
+	}
+	return nil
+}
+
+// save writes the file sorted by machine and strategy, so that updating one
+// entry gives a small diff.
+func (b *baselineFile) save(path string) error {
+	sort.Slice(b.Baselines, func(i, j int) bool {
+		x, y := b.Baselines[i], b.Baselines[j]
+		if x.Machine != y.Machine {
+			return x.Machine.String() < y.Machine.String()
+		}
+		return x.Strategy < y.Strategy
+	})
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// newBaselineEntry summarizes the runs of a strategy on this machine.
+func newBaselineEntry(fi functionInfo, durations []time.Duration) baselineEntry {
+	samples := make([]int64, len(durations))
+	for i, d := range durations {
+		samples[i] = int64(d)
+	}
+	m := median(samples)
+	deviations := make([]int64, len(samples))
+	best := samples[0]
+	for i, s := range samples {
+		deviations[i] = int64(math.Abs(float64(s) - m))
+		best = min(best, s)
+	}
+
+	e := baselineEntry{
+		Machine:  currentFingerprint(),
+		Strategy: fi.name,
+		Runs:     len(samples),
+		BestNs:   best,
+		MedianNs: int64(m),
+		MADNs:    int64(median(deviations)),
+		Recorded: time.Now().UTC().Truncate(time.Second),
+	}
+	if stat, err := os.Stat(fi.file); err == nil {
+		e.FileBytes = stat.Size()
+	}
+	return e
+}
+
+// allowedSlowdown is the slowdown in percent the gate tolerates: maxPercent,
+// or noise times the relative noise of the baseline or of this run, whichever
+// is larger. The noise is 1.4826*MAD/median, which estimates the relative
+// standard deviation without being thrown off by a few outliers.
+func allowedSlowdown(base, cur baselineEntry, maxPercent, noise float64) float64 {
+	relative := func(e baselineEntry) float64 { return 1.4826 * float64(e.MADNs) / float64(e.MedianNs) }
+	return math.Max(maxPercent, noise*100*math.Max(relative(base), relative(cur)))
+}
+
+// gateCommand runs a strategy under the repetition tester and fails when its
+// best or median time is slower than the baseline recorded on a machine with
+// the same fingerprint by more than the allowed slowdown. With -update it
+// records the runs as the new baseline for this machine instead.
+func gateCommand(args []string) error {
+	flags := newFlagSet("gate")
+	strategyName := flags.String("strategy", "parse", "strategy to run; parse is the parse function in main.go")
+	baselinePath := flags.String("baseline", "baseline.json", "baseline file")
+	runs := flags.Int("runs", 10, "number of runs")
+	maxSlowdown := flags.Float64("max-slowdown", 5, "slowdown in percent that is always tolerated")
+	noise := flags.Float64("noise", 3, "also tolerate this many relative standard deviations of the timings")
+	update := flags.Bool("update", false, "record the runs as the baseline of this machine instead of checking them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *runs < 1 {
+		return fmt.Errorf("-runs must be positive")
+	}
+	fi, err := findStrategy(*strategyName)
+	if err != nil {
+		return err
+	}
+	baselines, err := loadBaselines(*baselinePath)
+	if err != nil {
+		return err
+	}
+	machine := currentFingerprint()
+	base := baselines.find(machine, fi.name)
+	if base == nil && !*update {
+		return fmt.Errorf("no baseline for %s on this machine (%s) in %s, record one with -update", fi.name, machine, *baselinePath)
+	}
+
+	durations := make([]time.Duration, 0, *runs)
+	for i := 0; i < *runs; i++ {
+		execTime, s1, s2, lines := run(fi)
+		if !fi.approximate() {
+			if err := verify(fi.file, s1, s2, lines); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%s: %w", fi.name, err)
+			}
+		}
+		durations = append(durations, execTime)
+	}
+	cur := newBaselineEntry(fi, durations)
+
+	if *update {
+		if base != nil {
+			*base = cur
+		} else {
+			baselines.Baselines = append(baselines.Baselines, cur)
+		}
+		if err := baselines.save(*baselinePath); err != nil {
+			return err
+		}
+		fmt.Printf("recorded %s on %s: best %v, median %v\n", fi.name, machine,
+			time.Duration(cur.BestNs), time.Duration(cur.MedianNs))
+		return nil
+	}
+
+	if base.FileBytes != cur.FileBytes {
+		return fmt.Errorf("the baseline was recorded on a %d byte %s, this one has %d bytes", base.FileBytes, fi.file, cur.FileBytes)
+	}
+
+	allowed := allowedSlowdown(*base, cur, *maxSlowdown, *noise)
+	fmt.Printf("machine: %s\nstrategy: %s, %d runs, allowed slowdown %.2f%%\n", machine, fi.name, cur.Runs, allowed)
+	failed := false
+	for _, m := range []struct {
+		name      string
+		base, cur int64
+	}{
+		{"best", base.BestNs, cur.BestNs},
+		{"median", base.MedianNs, cur.MedianNs},
+	} {
+		change := 100 * float64(m.cur-m.base) / float64(m.base)
+		verdict := "ok"
+		if change > allowed {
+			verdict = "REGRESSION"
+			failed = true
+		}
+		fmt.Printf("%-6s baseline %12v now %12v %+8.2f%%  %s\n", m.name,
+			time.Duration(m.base).Round(time.Microsecond), time.Duration(m.cur).Round(time.Microsecond), change, verdict)
+	}
+	if failed {
+		return fmt.Errorf("%s is slower than the baseline by more than %.2f%%", fi.name, allowed)
+	}
+	return nil
+}
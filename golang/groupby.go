@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// groups maps a bucket index of the key column to the statistics of the
+// value column for the lines that fall into it. Bucket i holds the keys in
+// [i*width, (i+1)*width).
+type groups map[int64]*columnStats
+
+// widthHundredths returns the bucket width in hundredths. Buckets are
+// computed on keys in integer hundredths, the precision of the points files,
+// so that a key on a bucket boundary always starts its bucket; in floating
+// point 0.3/0.1 is 2.9999999999999996 and 0.3 would land in bucket 2.
+func widthHundredths(width float64) (int64, error) {
+	h := math.Round(width * 100)
+	if !(h >= 1 && h < 1<<53) || math.Abs(width*100-h) > 1e-6 {
+		return 0, fmt.Errorf("bucket width must be a positive multiple of 0.01, got %g", width)
+	}
+	return int64(h), nil
+}
+
+// bucketOf returns the bucket of key for a width in hundredths, the floor of
+// key/width. Keys in whole hundredths are floored in integers, so that a key on
+// a bucket boundary starts its bucket; finer keys are floored at full
+// precision, so 0.999 stays in bucket 0 of width 1 and -0.001 goes to bucket
+// -1. ok is false for keys that are not finite or too large to count in
+// hundredths.
+func bucketOf(key float64, width int64) (int64, bool) {
+	scaled := key * 100
+	if !(math.Abs(scaled) < 1<<53) {
+		return 0, false
+	}
+	h := math.Round(scaled)
+	if math.Abs(scaled-h) > 1e-9*max(1, math.Abs(h)) {
+		return int64(math.Floor(scaled / float64(width))), true
+	}
+	b := int64(h) / width
+	if int64(h)%width < 0 {
+		b-- // Round down rather than towards zero
+	}
+	return b, true
+}
+
+func (g groups) add(bucket int64, v float64) {
+	s, ok := g[bucket]
+	if !ok {
+		s = &columnStats{}
+		g[bucket] = s
+	}
+	s.add(v)
+}
+
+func (g groups) merge(o groups) {
+	for bucket, s := range o {
+		if own, ok := g[bucket]; ok {
+			own.merge(*s)
+		} else {
+			g[bucket] = s
+		}
+	}
+}
+
+// groupByReadAndSum aggregates one column grouped by buckets of the other in
+// a single pass. by is the key column ("x" or "y"); the other one is the value
+// column. Lines whose key bucketOf rejects are skipped. Every worker fills
+// its own groups, which are merged at the end.
+func groupByReadAndSum(filePath string, f format, by string, width float64) (groups, error) {
+	w, err := widthHundredths(width)
+	if err != nil {
+		return nil, err
+	}
+
+	byX := by == "x"
+	results, err := readChunks(filePath, func(data []byte) groups {
+		local := groups{}
+		forEachPoint(data, f, func(x, y float64) {
+			key, value := y, x
+			if byX {
+				key, value = x, y
+			}
+			if bucket, ok := bucketOf(key, w); ok {
+				local.add(bucket, value)
+			}
+		})
+		return local
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total := groups{}
+	for _, res := range results {
+		total.merge(res)
+	}
+	return total, nil
+}
+
+type groupJSON struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+// groupByCommand prints the average of one column per bucket of the other,
+// as a table or as JSON.
+func groupByCommand(args []string) error {
+	flags := newFlagSet("groupby")
+	in := addInputFlags(flags, "file to parse")
+	by := flags.String("by", "x", "column to bucket by, x or y; the other column is averaged")
+	width := flags.Float64("width", 1, "bucket width, a multiple of 0.01; 1 groups by the integer part (rounded down)")
+	output := flags.String("output", "table", "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *by != "x" && *by != "y" {
+		return fmt.Errorf("unknown column %q (want x or y)", *by)
+	}
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	res, err := groupByReadAndSum(*in.file, f, *by, *width)
+	if err != nil {
+		return err
+	}
+
+	buckets := make([]int64, 0, len(res))
+	for b := range res {
+		buckets = append(buckets, b)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	value := "y"
+	if *by == "y" {
+		value = "x"
+	}
+
+	w, _ := widthHundredths(*width) // Checked by groupByReadAndSum
+	from := func(b int64) float64 { return float64(b*w) / 100 }
+
+	switch *output {
+	case "table":
+		fmt.Printf("%10s %10s %12s %12s %10s %10s\n", *by+" from", "to", "count", "avg "+value, "min", "max")
+		for _, b := range buckets {
+			s := res[b]
+			fmt.Printf("%10.2f %10.2f %12d %12.6f %10.2f %10.2f\n",
+				from(b), from(b+1), s.count, s.mean, s.min, s.max)
+		}
+	case "json":
+		out := struct {
+			By     string      `json:"by"`
+			Value  string      `json:"value"`
+			Width  float64     `json:"width"`
+			Groups []groupJSON `json:"groups"`
+		}{By: *by, Value: value, Width: *width}
+		for _, b := range buckets {
+			s := res[b]
+			out.Groups = append(out.Groups, groupJSON{
+				From: from(b), To: from(b + 1),
+				Count: s.count, Avg: s.mean, Min: s.min, Max: s.max,
+			})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	default:
+		return fmt.Errorf("unknown output %q (want table or json)", *output)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBucketOf(t *testing.T) {
+	tests := []struct {
+		key    float64
+		width  float64
+		bucket int64
+		ok     bool
+	}{
+		{0.3, 0.1, 3, true}, // math.Floor(0.3/0.1) is 2
+		{0.29, 0.1, 2, true},
+		{0.7, 0.1, 7, true},
+		{0, 1, 0, true},
+		{0.99, 1, 0, true},
+		{-0.01, 1, -1, true},
+		{-1, 1, -1, true},
+		{-1.01, 1, -2, true},
+		{-99.99, 0.5, -200, true},
+		{99.99, 0.5, 199, true},
+		{5, 2.5, 2, true},
+		{0.57, 0.57, 1, true}, // 0.57*100 is 56.99999999999999
+		{0.999, 1, 0, true},   // Finer than hundredths
+		{-0.001, 1, -1, true},
+		{0.005, 0.01, 0, true},
+		{-0.005, 0.01, -1, true},
+		{1.999, 0.5, 3, true},
+		{-1.999, 0.5, -4, true},
+		{2.0000001, 1, 2, true},
+		{1.9999999, 1, 1, true},
+		{math.Inf(1), 1, 0, false},
+		{math.Inf(-1), 1, 0, false},
+		{math.NaN(), 1, 0, false},
+		{1e300, 1, 0, false},
+	}
+	for _, tt := range tests {
+		w, err := widthHundredths(tt.width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bucket, ok := bucketOf(tt.key, w)
+		if bucket != tt.bucket || ok != tt.ok {
+			t.Errorf("bucketOf(%v, width %v) = %d, %v, want %d, %v", tt.key, tt.width, bucket, ok, tt.bucket, tt.ok)
+		}
+	}
+}
+
+func TestWidthHundredths(t *testing.T) {
+	for _, width := range []float64{0, -1, 0.001, 0.015, math.NaN(), math.Inf(1)} {
+		if w, err := widthHundredths(width); err == nil {
+			t.Errorf("widthHundredths(%v) = %d, want an error", width, w)
+		}
+	}
+	for width, want := range map[float64]int64{0.01: 1, 0.1: 10, 0.3: 30, 1: 100, 2.5: 250} {
+		if w, err := widthHundredths(width); err != nil || w != want {
+			t.Errorf("widthHundredths(%v) = %d, %v, want %d", width, w, err, want)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	data := "0.30,1\n0.35,3\n0.29,10\n-0.01,7\n0.099,20\n-0.001,40\n1e999,100\nNaN,100\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := groupByReadAndSum(path, formats[0], "x", 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]struct {
+		count int64
+		sum   float64
+	}{3: {2, 4}, 2: {1, 10}, 0: {1, 20}, -1: {2, 47}}
+	if len(res) != len(want) {
+		t.Errorf("got %d buckets, want %d", len(res), len(want))
+	}
+	for b, w := range want {
+		if s := res[b]; s == nil || s.count != w.count || s.sum != w.sum {
+			t.Errorf("bucket %d = %+v, want count %d sum %v", b, s, w.count, w.sum)
+		}
+	}
+
+	if _, err := groupByReadAndSum(path, formats[0], "x", 0); err == nil {
+		t.Error("width 0 did not fail")
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The generator writes values between -99.99 and 99.99 with two decimals, so
+// every column can only hold 19,999 different values. Counting each of them
+// gives exact quantiles and modes with a fixed amount of memory.
+const (
+	histogramMin     = -9999 // In hundredths
+	histogramMax     = 9999
+	histogramBuckets = histogramMax - histogramMin + 1
+)
+
+// histogram counts the occurrences of every possible value of a column.
+type histogram struct {
+	counts [histogramBuckets]int64
+	total  int64
+}
+
+func (h *histogram) add(hundredths int64) {
+	h.counts[hundredths-histogramMin]++
+	h.total++
+}
+
+func (h *histogram) merge(o *histogram) {
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.total += o.total
+}
+
+func bucketValue(i int) float64 {
+	return float64(i+histogramMin) / 100
+}
+
+// percentile returns the smallest value whose rank is at least q*n.
+func (h *histogram) percentile(q float64) float64 {
+	target := q * float64(h.total)
+	var cum int64
+	for i, c := range h.counts {
+		cum += c
+		if c > 0 && float64(cum) >= target {
+			return bucketValue(i)
+		}
+	}
+	return math.NaN()
+}
+
+// mode returns the most frequent value and how often it occurs. Ties go to
+// the smallest value.
+func (h *histogram) mode() (float64, int64) {
+	best := 0
+	for i, c := range h.counts {
+		if c > h.counts[best] {
+			best = i
+		}
+	}
+	return bucketValue(best), h.counts[best]
+}
+
+func (h *histogram) sum() float64 {
+	var s int64
+	for i, c := range h.counts {
+		s += c * int64(i+histogramMin)
+	}
+	return float64(s) / 100
+}
+
+// histogramReadAndSum counts every value of both columns. Each worker fills
+// its own pair of histograms, which are added up at the end. Values that do
+// not have exactly two decimals or fall outside ±99.99 are an error.
+func histogramReadAndSum(filePath string, f format) (*histogram, *histogram, error) {
+	type partial struct {
+		x, y *histogram
+		bad  []byte // First line that does not fit the histogram
+	}
+	results, err := readChunks(filePath, func(data []byte) partial {
+		local := partial{x: new(histogram), y: new(histogram)}
+
+		lineStart := 0
+		for i := 0; i <= len(data); i++ {
+			if i == len(data) || data[i] == '\n' {
+				line := data[lineStart:i]
+				lineStart = i + 1
+				a, b, ok := f.split(line)
+				if !ok {
+					continue // Skip malformed lines
+				}
+				x, okX := parseFixed2(a, f.decimal)
+				y, okY := parseFixed2(b, f.decimal)
+				if !okX || !okY || x < histogramMin || x > histogramMax || y < histogramMin || y > histogramMax {
+					local.bad = line
+					break
+				}
+				local.x.add(x)
+				local.y.add(y)
+			}
+		}
+		return local
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	x, y := new(histogram), new(histogram)
+	for _, res := range results {
+		if res.bad != nil {
+			return nil, nil, fmt.Errorf("line %q is not two-decimal values within ±99.99", trimLine(res.bad))
+		}
+		x.merge(res.x)
+		y.merge(res.y)
+	}
+	return x, y, nil
+}
+
+// generatorCDF is the probability that the generator writes a value of the
+// given column below v. x is uniform on [-a, a) and y = x + U*(a-x), whose
+// density (1/2a)*ln(2a/(a-y)) integrates to 1 - (a-y)/2a * (1 + ln(2a/(a-y))).
+func generatorCDF(column string, v float64) float64 {
+	const a = 99.99
+	switch {
+	case v <= -a:
+		return 0
+	case v >= a:
+		return 1
+	case column == "x":
+		return (v + a) / (2 * a)
+	}
+	u := a - v
+	return 1 - u/(2*a)*(1+math.Log(2*a/u))
+}
+
+// ksDistance is the Kolmogorov-Smirnov distance between the histogram and
+// the generator's distribution. A value rounded to v covers [v-0.005, v+0.005).
+func ksDistance(h *histogram, column string) float64 {
+	var d float64
+	var cum int64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		v := bucketValue(i)
+		below := float64(cum) / float64(h.total)
+		cum += c
+		upTo := float64(cum) / float64(h.total)
+		d = math.Max(d, math.Abs(below-generatorCDF(column, v-0.005)))
+		d = math.Max(d, math.Abs(upTo-generatorCDF(column, v+0.005)))
+	}
+	return d
+}
+
+func printHistogramASCII(name string, h *histogram, binWidth int) {
+	const barWidth = 50
+	bins := binCounts(h, binWidth)
+	var most int64
+	for _, c := range bins {
+		if c > most {
+			most = c
+		}
+	}
+
+	fmt.Printf("%s:\n", name)
+	for i, c := range bins {
+		start := bucketValue(i * binWidth)
+		bar := 0
+		if most > 0 {
+			bar = int(c * barWidth / most)
+		}
+		fmt.Printf("%8.2f | %-*s %d\n", start, barWidth, strings.Repeat("#", bar), c)
+	}
+}
+
+// binCounts adds up binWidth consecutive buckets into one bin.
+func binCounts(h *histogram, binWidth int) []int64 {
+	bins := make([]int64, (histogramBuckets+binWidth-1)/binWidth)
+	for i, c := range h.counts {
+		bins[i/binWidth] += c
+	}
+	return bins
+}
+
+func printHistogramCSV(x, y *histogram, binWidth int) {
+	fmt.Println("bin_start,bin_end,x,y")
+	xs, ys := binCounts(x, binWidth), binCounts(y, binWidth)
+	for i := range xs {
+		last := min((i+1)*binWidth, histogramBuckets) - 1
+		fmt.Printf("%.2f,%.2f,%d,%d\n", bucketValue(i*binWidth), bucketValue(last), xs[i], ys[i])
+	}
+}
+
+// histogramCommand prints the exact median, mode and percentiles of both
+// columns and optionally a histogram. -validate compares the counts with the
+// distribution the generator draws from.
+func histogramCommand(args []string) error {
+	flags := newFlagSet("histogram")
+	in := addInputFlags(flags, "file to parse")
+	percentiles := flags.String("percentiles", "50,90,99", "comma separated percentiles to report")
+	output := flags.String("output", "none", "histogram to print: none, ascii or csv")
+	bin := flags.Float64("bin", 10, "histogram bin width")
+	validate := flags.Bool("validate", false, "check the counts against the generator's distribution with a Kolmogorov-Smirnov test")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+	binWidth := int(math.Round(*bin * 100))
+	if binWidth < 1 {
+		return fmt.Errorf("bin width must be at least 0.01")
+	}
+	var qs []float64
+	for _, p := range strings.Split(*percentiles, ",") {
+		q, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || q < 0 || q > 100 {
+			return fmt.Errorf("invalid percentile %q", p)
+		}
+		qs = append(qs, q/100)
+	}
+
+	x, y, err := histogramReadAndSum(*in.file, f)
+	if err != nil {
+		return err
+	}
+
+	columns := []struct {
+		name string
+		h    *histogram
+	}{{"x", x}, {"y", y}}
+
+	if *output != "csv" {
+		fmt.Printf("lines: %d\n", x.total)
+		for _, c := range columns {
+			mode, count := c.h.mode()
+			fmt.Printf("%s: sum %.2f avg %.6f mode %.2f (%d times)", c.name, c.h.sum(), c.h.sum()/float64(c.h.total), mode, count)
+			for _, q := range qs {
+				fmt.Printf(" p%g %.2f", q*100, c.h.percentile(q))
+			}
+			fmt.Println()
+		}
+	}
+
+	switch *output {
+	case "none":
+	case "ascii":
+		for _, c := range columns {
+			printHistogramASCII(c.name, c.h, binWidth)
+		}
+	case "csv":
+		printHistogramCSV(x, y, binWidth)
+	default:
+		return fmt.Errorf("unknown histogram output %q (want none, ascii or csv)", *output)
+	}
+
+	if *validate {
+		// Critical value of the Kolmogorov-Smirnov test at the 1% level
+		critical := 1.628 / math.Sqrt(float64(x.total))
+		failed := false
+		for _, c := range columns {
+			d := ksDistance(c.h, c.name)
+			fmt.Fprintf(os.Stderr, "%s: KS distance from the generator's distribution %.6f (critical %.6f)\n", c.name, d, critical)
+			failed = failed || d > critical
+		}
+		if failed {
+			return fmt.Errorf("counts do not follow the generator's distribution")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHistogramReadAndSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	rng := rand.New(rand.NewSource(7))
+	const lines = 5001
+	var xs, ys []int64
+	var b strings.Builder
+	for i := 0; i < lines; i++ {
+		// x spread over the whole domain, y over few values so the mode is clear.
+		x, y := rng.Int63n(histogramBuckets)+histogramMin, rng.Int63n(41)*5-100
+		fmt.Fprintf(&b, "%.2f,%.2f\n", float64(x)/100, float64(y)/100)
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	x, y, err := histogramReadAndSum(path, formats[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name   string
+		h      *histogram
+		values []int64
+	}{{"x", x, xs}, {"y", y, ys}} {
+		sorted := append([]int64(nil), c.values...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		if c.h.total != lines {
+			t.Fatalf("%s: counted %d values, want %d", c.name, c.h.total, lines)
+		}
+
+		// The smallest value whose rank is at least q*n.
+		for _, q := range []float64{0, 0.01, 0.25, 0.5, 0.9, 0.99, 1} {
+			rank := max(int(math.Ceil(q*lines)), 1)
+			if got, want := c.h.percentile(q), float64(sorted[rank-1])/100; got != want {
+				t.Errorf("%s: percentile(%v) = %v, want %v", c.name, q, got, want)
+			}
+		}
+
+		var sum int64
+		counts := make(map[int64]int64)
+		for _, v := range sorted {
+			sum += v
+			counts[v]++
+		}
+		var mode, modeCount int64
+		for _, v := range sorted {
+			if counts[v] > modeCount {
+				mode, modeCount = v, counts[v]
+			}
+		}
+		if v, n := c.h.mode(); v != float64(mode)/100 || n != modeCount {
+			t.Errorf("%s: mode = %v (%d times), want %v (%d times)", c.name, v, n, float64(mode)/100, modeCount)
+		}
+		if got := c.h.sum(); got != float64(sum)/100 {
+			t.Errorf("%s: sum = %v, want %v", c.name, got, float64(sum)/100)
+		}
+	}
+}
+
+func TestHistogramRejects(t *testing.T) {
+	for _, data := range []string{"1.00,2.00\n1.5,2.00\n", "1.00,2.00\n100.00,2.00\n", "1.00,-99.991\n"} {
+		path := filepath.Join(t.TempDir(), "points.txt")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := histogramReadAndSum(path, formats[0]); err == nil {
+			t.Errorf("%q did not fail", data)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// A line index is a sidecar file (points.txt.idx) with the byte offset of
+// every stride-th line of a points file and the sums of both columns over all
+// lines before it. With it, averages over any line range are answered by
+// parsing at most two partial blocks, and chunked commands split the file on
+// line boundaries without looking for newlines.
+//
+// Layout, all little-endian:
+//
+//	indexHeader
+//	indexEntry * Entries   entry k describes line k*Stride, the last one
+//	                       the end of the file with the sums of all lines
+//
+// Only lines with two valid numbers count as lines. Sums are kept in integer
+// hundredths, so they stay exact however long the file is, and a file with
+// more than two decimals cannot be indexed. The index records the
+// size and modification time of the file it was built from and is rejected
+// once either changes.
+const (
+	indexVersion = 3
+	indexSuffix  = ".idx"
+)
+
+var indexMagic = [4]byte{'P', 'I', 'D', 'X'}
+
+var errStaleIndex = errors.New("index does not match the file, rebuild it")
+
+type indexHeader struct {
+	Magic     [4]byte
+	Version   uint32
+	Delimiter byte
+	Decimal   byte
+	_         [6]byte
+	Stride    int64
+	FileSize  int64
+	ModTime   int64 // Unix nanoseconds
+	Lines     int64
+	Entries   int64
+}
+
+type indexEntry struct {
+	Offset int64
+	SumX   int64 // Sum of the lines before this one, in hundredths
+	SumY   int64
+}
+
+type lineIndex struct {
+	header  indexHeader
+	entries []indexEntry
+}
+
+// buildIndex reads the whole file once and writes its index next to it.
+func buildIndex(filePath string, f format, stride int64) (*lineIndex, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &lineIndex{header: indexHeader{
+		Magic:     indexMagic,
+		Version:   indexVersion,
+		Delimiter: f.delimiter,
+		Decimal:   f.decimal,
+		Stride:    stride,
+		FileSize:  stat.Size(),
+		ModTime:   stat.ModTime().UnixNano(),
+	}}
+
+	reader := bufio.NewReaderSize(file, 1<<20)
+	var offset int64
+	var sumX, sumY, lines int64
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, fmt.Errorf("line at offset %d is too long", offset)
+		}
+		if len(line) > 0 {
+			if x, y, ok := f.parsePoint(trimNewline(line)); ok {
+				hx, okX := hundredths(x)
+				hy, okY := hundredths(y)
+				if !okX || !okY {
+					return nil, fmt.Errorf("line at offset %d has more than two decimals", offset)
+				}
+				if lines%stride == 0 {
+					idx.entries = append(idx.entries, indexEntry{Offset: offset, SumX: sumX, SumY: sumY})
+				}
+				sumX += hx
+				sumY += hy
+				lines++
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	idx.entries = append(idx.entries, indexEntry{Offset: offset, SumX: sumX, SumY: sumY})
+	idx.header.Lines = lines
+	idx.header.Entries = int64(len(idx.entries))
+
+	out, err := os.Create(filePath + indexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(out)
+	if err := binary.Write(w, binary.LittleEndian, idx.header); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, idx.entries); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	cacheIndex(filePath, idx)
+	return idx, nil
+}
+
+// hundredths converts a parsed value to integer hundredths, failing when it
+// has more than two decimals.
+func hundredths(v float64) (int64, bool) {
+	h := math.Round(v * 100)
+	return int64(h), math.Abs(h-v*100) < 1e-6
+}
+
+func trimNewline(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		return line[:n-1]
+	}
+	return line
+}
+
+// readIndexHeader reads and checks the header of the index of filePath
+// against the file's current size and modification time.
+func readIndexHeader(r io.Reader, filePath string) (indexHeader, error) {
+	var h indexHeader
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return h, err
+	}
+	if h.Magic != indexMagic {
+		return h, fmt.Errorf("%s%s is not a line index", filePath, indexSuffix)
+	}
+	if h.Version != indexVersion {
+		return h, fmt.Errorf("line index version %d is not supported (want %d)", h.Version, indexVersion)
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return h, err
+	}
+	if stat.Size() != h.FileSize || stat.ModTime().UnixNano() != h.ModTime {
+		return h, errStaleIndex
+	}
+	return h, nil
+}
+
+// loadIndex reads the index of filePath, failing if it is missing or stale.
+func loadIndex(filePath string) (*lineIndex, error) {
+	in, err := os.Open(filePath + indexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	h, err := readIndexHeader(r, filePath)
+	if err != nil {
+		return nil, err
+	}
+	idx := &lineIndex{header: h, entries: make([]indexEntry, h.Entries)}
+	if err := binary.Read(r, binary.LittleEndian, idx.entries); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *lineIndex) format() (format, error) {
+	for _, f := range formats {
+		if f.delimiter == idx.header.Delimiter && f.decimal == idx.header.Decimal {
+			return f, nil
+		}
+	}
+	return format{}, fmt.Errorf("line index uses an unknown format")
+}
+
+// prefix returns the sums of the first n lines in hundredths. It starts from
+// the closest entry at or before line n and parses the remaining lines after it.
+func (idx *lineIndex) prefix(file *os.File, f format, n int64) (int64, int64, error) {
+	if n == idx.header.Lines {
+		e := idx.entries[len(idx.entries)-1]
+		return e.SumX, e.SumY, nil
+	}
+	e := idx.entries[n/idx.header.Stride]
+	sumX, sumY := e.SumX, e.SumY
+	remaining := n % idx.header.Stride
+	if remaining == 0 {
+		return sumX, sumY, nil
+	}
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(file, e.Offset, idx.header.FileSize-e.Offset), 1<<16)
+	for remaining > 0 {
+		line, err := reader.ReadSlice('\n')
+		if err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		if x, y, ok := f.parsePoint(trimNewline(line)); ok {
+			hx, _ := hundredths(x)
+			hy, _ := hundredths(y)
+			sumX += hx
+			sumY += hy
+			remaining--
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return sumX, sumY, nil
+}
+
+// rangeSums returns the sums of lines [from, to) using the index.
+func rangeSums(filePath string, from, to int64) (float64, float64, error) {
+	idx, err := loadIndex(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from < 0 || to > idx.header.Lines || from > to {
+		return 0, 0, fmt.Errorf("range [%d, %d) is outside the %d lines of the file", from, to, idx.header.Lines)
+	}
+	f, err := idx.format()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	fromX, fromY, err := idx.prefix(file, f, from)
+	if err != nil {
+		return 0, 0, err
+	}
+	toX, toY, err := idx.prefix(file, f, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(toX-fromX) / 100, float64(toY-fromY) / 100, nil
+}
+
+// indexes caches the line index of every file split by forEachChunk, so that
+// repeated passes over a file read its index only once. Entries are keyed by
+// the file's size and modification time and so never outlive a change.
+var indexes = struct {
+	sync.Mutex
+	m map[indexKey]*lineIndex
+}{m: make(map[indexKey]*lineIndex)}
+
+type indexKey struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+func indexKeyOf(filePath string) (indexKey, error) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return indexKey{}, err
+	}
+	stat, err := os.Stat(abs)
+	if err != nil {
+		return indexKey{}, err
+	}
+	return indexKey{abs, stat.Size(), stat.ModTime().UnixNano()}, nil
+}
+
+// cacheIndex records an index that was just built for filePath.
+func cacheIndex(filePath string, idx *lineIndex) {
+	key, err := indexKeyOf(filePath)
+	if err != nil || key.size != idx.header.FileSize || key.modTime != idx.header.ModTime {
+		return
+	}
+	indexes.Lock()
+	indexes.m[key] = idx
+	indexes.Unlock()
+}
+
+// cachedIndex returns the index of filePath, loading it on first use, or nil
+// when the file has no up to date index.
+func cachedIndex(filePath string) *lineIndex {
+	key, err := indexKeyOf(filePath)
+	if err != nil {
+		return nil
+	}
+	indexes.Lock()
+	defer indexes.Unlock()
+	idx, ok := indexes.m[key]
+	if !ok {
+		idx, _ = loadIndex(filePath)
+		indexes.m[key] = idx
+	}
+	return idx
+}
+
+// chunks splits [start, end) of the indexed file into at most n chunks at
+// line offsets taken from the index, without reading the file itself.
+func (idx *lineIndex) chunks(start, end int64, n int) []chunk {
+	chunkSize := (end - start) / int64(n)
+	chunks := make([]chunk, 0, n)
+	offset := start
+	for i := 1; i < n; i++ {
+		boundary := start + int64(i)*chunkSize
+		if boundary <= offset {
+			continue
+		}
+		k := sort.Search(len(idx.entries), func(k int) bool { return idx.entries[k].Offset >= boundary })
+		if k == len(idx.entries) || idx.entries[k].Offset >= end {
+			break
+		}
+		next := idx.entries[k].Offset
+		chunks = append(chunks, chunk{offset: offset, size: next - offset})
+		offset = next
+	}
+	if offset < end {
+		chunks = append(chunks, chunk{offset: offset, size: end - offset})
+	}
+	return chunks
+}
+
+// indexCommand builds the line index of a points file.
+func indexCommand(args []string) error {
+	flags := newFlagSet("index")
+	in := addInputFlags(flags, "file to index")
+	stride := flags.Int64("stride", 1000, "record the offset of every stride-th line")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *stride < 1 {
+		return fmt.Errorf("-stride must be positive")
+	}
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	idx, err := buildIndex(*in.file, f, *stride)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("indexed %d lines in %d entries into %s%s\n", idx.header.Lines, idx.header.Entries, *in.file, indexSuffix)
+	return nil
+}
+
+// rangeCommand prints the sums and averages of lines [from, to) using the
+// line index built by the index command.
+func rangeCommand(args []string) error {
+	flags := newFlagSet("range")
+	filePath := flags.String("file", "points.txt", "indexed file")
+	from := flags.Int64("from", 0, "first line of the range (0 based)")
+	to := flags.Int64("to", -1, "line after the last one of the range (default the end of the file)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *to == -1 {
+		idx, err := loadIndex(*filePath)
+		if err != nil {
+			return err
+		}
+		*to = idx.header.Lines
+	}
+
+	sumX, sumY, err := rangeSums(*filePath, *from, *to)
+	if err != nil {
+		return err
+	}
+	lines := *to - *from
+	fmt.Printf("lines: [%d, %d) = %d\n", *from, *to, lines)
+	fmt.Printf("x: sum %.2f avg %.6f\n", sumX, sumX/float64(lines))
+	fmt.Printf("y: sum %.2f avg %.6f\n", sumY, sumY/float64(lines))
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writePoints writes one point per line and returns the values written.
+func writePoints(t *testing.T, path string, lines int) (xs, ys []float64) {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < lines; i++ {
+		x, y := float64(i%200-100)+0.25, float64(i%37)-0.5
+		fmt.Fprintf(&b, "%.2f,%.2f\n", x, y)
+		xs, ys = append(xs, x), append(ys, y)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return xs, ys
+}
+
+func TestRangeSums(t *testing.T) {
+	tests := []struct {
+		lines  int
+		stride int64
+	}{
+		{10, 5},  // Lines an exact multiple of the stride
+		{12, 5},  // A partial block after the last entry
+		{7, 1},   // An entry for every line
+		{3, 100}, // Fewer lines than the stride
+		{200, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d lines stride %d", tt.lines, tt.stride), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "points.txt")
+			xs, ys := writePoints(t, path, tt.lines)
+			idx, err := buildIndex(path, formats[0], tt.stride)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx.header.Lines != int64(tt.lines) {
+				t.Fatalf("indexed %d lines, want %d", idx.header.Lines, tt.lines)
+			}
+
+			for from := 0; from <= tt.lines; from++ {
+				for to := from; to <= tt.lines; to++ {
+					var hx, hy int64
+					for i := from; i < to; i++ {
+						hx += int64(math.Round(xs[i] * 100))
+						hy += int64(math.Round(ys[i] * 100))
+					}
+					wantX, wantY := float64(hx)/100, float64(hy)/100
+					sumX, sumY, err := rangeSums(path, int64(from), int64(to))
+					if err != nil {
+						t.Fatalf("rangeSums(%d, %d): %v", from, to, err)
+					}
+					if sumX != wantX || sumY != wantY {
+						t.Errorf("rangeSums(%d, %d) = %v, %v, want %v, %v", from, to, sumX, sumY, wantX, wantY)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRangeSumsOutside(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	writePoints(t, path, 10)
+	if _, err := buildIndex(path, formats[0], 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]int64{{-1, 5}, {0, 11}, {6, 5}} {
+		if _, _, err := rangeSums(path, r[0], r[1]); err == nil {
+			t.Errorf("rangeSums(%d, %d) did not fail", r[0], r[1])
+		}
+	}
+}
+
+func TestStaleIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	writePoints(t, path, 10)
+	if _, err := buildIndex(path, formats[0], 5); err != nil {
+		t.Fatal(err)
+	}
+	writePoints(t, path, 11)
+	if _, err := loadIndex(path); err != errStaleIndex {
+		t.Errorf("loadIndex after the file changed = %v, want errStaleIndex", err)
+	}
+}
+
+func TestIndexRejectsThreeDecimals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	if err := os.WriteFile(path, []byte("1.00,2.00\n1.005,2.00\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildIndex(path, formats[0], 1); err == nil {
+		t.Error("indexed a file with three decimals")
+	}
+}
+
+func TestIndexChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	writePoints(t, path, 1000)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := buildIndex(path, formats[0], 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets := make(map[int64]bool)
+	for _, e := range idx.entries {
+		offsets[e.Offset] = true
+	}
+
+	for _, start := range []int64{0, idx.entries[3].Offset} {
+		for n := 1; n <= 16; n++ {
+			chunks := idx.chunks(start, int64(len(data)), n)
+			if len(chunks) == 0 || len(chunks) > n {
+				t.Fatalf("%d chunks for %d workers", len(chunks), n)
+			}
+			offset := start
+			for i, c := range chunks {
+				if c.offset != offset || c.size <= 0 || !offsets[c.offset] {
+					t.Fatalf("start %d, %d workers: chunk %d is %+v, want it to start at indexed offset %d", start, n, i, c, offset)
+				}
+				offset += c.size
+			}
+			if offset != int64(len(data)) {
+				t.Errorf("start %d, %d workers: chunks end at %d, want %d", start, n, offset, len(data))
+			}
+		}
+	}
+}
+
+func TestCachedIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	writePoints(t, path, 100)
+	if cachedIndex(path) != nil {
+		t.Fatal("cachedIndex found an index before one was built")
+	}
+	idx, err := buildIndex(path, formats[0], 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cachedIndex(path) != idx {
+		t.Error("cachedIndex did not return the index just built")
+	}
+
+	// A later pass does not read the index file again.
+	if err := os.Remove(path + indexSuffix); err != nil {
+		t.Fatal(err)
+	}
+	if cachedIndex(path) != idx {
+		t.Error("cachedIndex read the index again")
+	}
+
+	writePoints(t, path, 101)
+	if cachedIndex(path) != nil {
+		t.Error("cachedIndex returned the index of the file before it changed")
+	}
+}
+
+func TestReadChunksWithIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	xs, ys := writePoints(t, path, 10000)
+	if _, err := buildIndex(path, formats[0], 10); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wantX, wantY float64
+	for i := range xs {
+		wantX += xs[i]
+		wantY += ys[i]
+	}
+	var mu sync.Mutex
+	var sumX, sumY float64
+	var lines, calls int
+	err = forEachChunk(file, 0, stat.Size(), 4, func(data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		forEachPoint(data, formats[0], func(x, y float64) {
+			sumX += x
+			sumY += y
+			lines++
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Errorf("forEachChunk made %d calls, want 4", calls)
+	}
+	if lines != len(xs) || math.Abs(sumX-wantX) > 1e-6 || math.Abs(sumY-wantY) > 1e-6 {
+		t.Errorf("read %v, %v from %d lines, want %v, %v from %d", sumX, sumY, lines, wantX, wantY, len(xs))
+	}
+}
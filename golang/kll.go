@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// kllSketch is a KLL quantile sketch (Karnin, Lang and Liberty, "Optimal
+// Quantile Approximation in Streams"). It keeps a stack of compactors where an
+// item at height h stands for 2^h values of the stream. A full compactor is
+// sorted and every other item is promoted to the next height, so memory stays
+// around 3k items no matter how many values are added, and the rank error of
+// a query is roughly 1.7/k. Sketches of the same k can be merged, which lets
+// every worker fill its own and combine them at the end.
+type kllSketch struct {
+	k          int
+	compactors [][]float64
+	size       int
+	maxSize    int
+	n          int64
+	coin       uint64 // State of the xorshift generator for compaction offsets
+}
+
+// newKLLSketch returns an empty sketch of size k. seed picks the compaction
+// offsets, so sketches that are merged should use different seeds.
+func newKLLSketch(k int, seed uint64) (*kllSketch, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("sketch size must be positive, got %d", k)
+	}
+	s := &kllSketch{k: k, coin: seed | 1}
+	s.grow()
+	return s, nil
+}
+
+// capacity of the compactor at height h; the top one holds k items and each
+// one below it two thirds of the one above.
+func (s *kllSketch) capacity(h int) int {
+	depth := len(s.compactors) - h - 1
+	return int(math.Ceil(math.Pow(2.0/3.0, float64(depth))*float64(s.k))) + 1
+}
+
+func (s *kllSketch) grow() {
+	s.compactors = append(s.compactors, nil)
+	s.maxSize = 0
+	for h := range s.compactors {
+		s.maxSize += s.capacity(h)
+	}
+}
+
+func (s *kllSketch) add(v float64) {
+	s.compactors[0] = append(s.compactors[0], v)
+	s.size++
+	s.n++
+	if s.size >= s.maxSize {
+		s.compress()
+	}
+}
+
+func (s *kllSketch) compress() {
+	for h := 0; h < len(s.compactors); h++ {
+		if len(s.compactors[h]) < s.capacity(h) {
+			continue
+		}
+		if h+1 >= len(s.compactors) {
+			s.grow()
+		}
+		s.compact(h)
+
+		s.size = 0
+		for _, c := range s.compactors {
+			s.size += len(c)
+		}
+		if s.size < s.maxSize {
+			break
+		}
+	}
+}
+
+// compact sorts the compactor at height h and promotes either the odd or the
+// even items, chosen at random, to height h+1. An odd item out stays behind.
+func (s *kllSketch) compact(h int) {
+	c := s.compactors[h]
+	sort.Float64s(c)
+
+	s.coin ^= s.coin << 13
+	s.coin ^= s.coin >> 7
+	s.coin ^= s.coin << 17
+	offset := int(s.coin & 1)
+
+	start := len(c) % 2
+	for i := start + offset; i < len(c); i += 2 {
+		s.compactors[h+1] = append(s.compactors[h+1], c[i])
+	}
+	s.compactors[h] = c[:start]
+}
+
+func (s *kllSketch) merge(o *kllSketch) {
+	for len(s.compactors) < len(o.compactors) {
+		s.grow()
+	}
+	for h, c := range o.compactors {
+		s.compactors[h] = append(s.compactors[h], c...)
+	}
+	s.n += o.n
+
+	s.size = 0
+	for _, c := range s.compactors {
+		s.size += len(c)
+	}
+	for s.size >= s.maxSize {
+		s.compress()
+	}
+}
+
+// quantiles returns the estimated value at each of the ranks qs (0..1), i.e.
+// the smallest value whose estimated rank is at least q*n.
+func (s *kllSketch) quantiles(qs ...float64) []float64 {
+	type weighted struct {
+		value  float64
+		weight int64
+	}
+	items := make([]weighted, 0, s.size)
+	for h, c := range s.compactors {
+		for _, v := range c {
+			items = append(items, weighted{v, 1 << h})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].value < items[j].value })
+
+	var total int64
+	for _, it := range items {
+		total += it.weight
+	}
+
+	res := make([]float64, len(qs))
+	for i, q := range qs {
+		res[i] = math.NaN()
+		target := q * float64(total)
+		var cum int64
+		for _, it := range items {
+			cum += it.weight
+			if float64(cum) >= target {
+				res[i] = it.value
+				break
+			}
+		}
+	}
+	return res
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -40,13 +44,11 @@ func vanillaReadAndSum(filePath string) (float64, float64, int64) {
 		data := string(buffer[:n])
 		for _, char := range data {
 			if char == '\n' {
-				commaIdx := strings.Index(line, ",")
-				x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-				y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
-
-				sumX += x
-				sumY += y
-				lines++
+				if x, y, ok := parseLineString(line); ok {
+					sumX += x
+					sumY += y
+					lines++
+				}
 				line = ""
 			} else {
 				line += string(char)
@@ -55,13 +57,11 @@ func vanillaReadAndSum(filePath string) (float64, float64, int64) {
 	}
 
 	if len(line) > 0 {
-		commaIdx := strings.Index(line, ",")
-		x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-		y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
-
-		sumX += x
-		sumY += y
-		lines++
+		if x, y, ok := parseLineString(line); ok {
+			sumX += x
+			sumY += y
+			lines++
+		}
 	}
 
 	return sumX, sumY, lines
@@ -69,24 +69,34 @@ func vanillaReadAndSum(filePath string) (float64, float64, int64) {
 
 // Concurrent implementation with workers
 func concurrentReadAndSum(filePath string) (float64, float64, int64) {
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan string, 100)
 	results := make(chan [3]float64, 100)
 	done := make(chan bool)
 
 	worker := func() {
+		tl := track("worker", phaseWait)
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-			y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseParse)
+			x, y, ok := parseLineString(line)
+			if !ok {
+				tl.enter(phaseWait)
+				continue // Skip malformed lines
+			}
+			tl.enter(phaseSend)
 			results <- [3]float64{x, y, 1}
+			tl.enter(phaseWait)
 		}
+		tl.done()
 		done <- true
 	}
 
@@ -98,6 +108,7 @@ func concurrentReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Read file and send lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, 1024)
 		line := ""
 		for {
@@ -107,13 +118,16 @@ func concurrentReadAndSum(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(lines)
 				return
 			}
 			data := string(buffer[:n])
 			for _, char := range data {
 				if char == '\n' {
+					tl.enter(phaseSend)
 					lines <- line
+					tl.enter(phaseRead)
 					line = ""
 				} else {
 					line += string(char)
@@ -121,8 +135,10 @@ func concurrentReadAndSum(filePath string) (float64, float64, int64) {
 			}
 		}
 		if len(line) > 0 {
+			tl.enter(phaseSend)
 			lines <- line
 		}
+		tl.done()
 		close(lines)
 	}()
 
@@ -149,12 +165,15 @@ func concurrentReadAndSum(filePath string) (float64, float64, int64) {
 // Optimized implementation with byte-level processing
 func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 	const bufferSize = 32768 // Large buffer for efficient I/O
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan string, 100)  // Channel for lines
 	results := make(chan [3]float64) // Channel for results
@@ -162,15 +181,19 @@ func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Worker function to process lines
 	worker := func() {
+		tl := track("worker", phaseWait)
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			if commaIdx == -1 {
+			tl.enter(phaseParse)
+			x, y, ok := parseLineString(line)
+			if !ok {
+				tl.enter(phaseWait)
 				continue // Skip malformed lines
 			}
-			x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-			y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseSend)
 			results <- [3]float64{x, y, 1}
+			tl.enter(phaseWait)
 		}
+		tl.done()
 		done <- struct{}{} // Signal that the worker is done
 	}
 
@@ -182,6 +205,7 @@ func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Goroutine to read the file and send lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, bufferSize)
 		line := ""
 
@@ -192,6 +216,7 @@ func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(lines)
 				return
 			}
@@ -199,7 +224,9 @@ func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 			data := string(buffer[:n])
 			for _, char := range data {
 				if char == '\n' {
+					tl.enter(phaseSend)
 					lines <- line
+					tl.enter(phaseRead)
 					line = ""
 				} else {
 					line += string(char)
@@ -209,8 +236,10 @@ func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 
 		// Send the last line if it doesn't end with a newline
 		if len(line) > 0 {
+			tl.enter(phaseSend)
 			lines <- line
 		}
+		tl.done()
 		close(lines) // Close the lines channel to signal no more input
 	}()
 
@@ -238,24 +267,34 @@ func optimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 // Better optimized implementation with channel aggregation
 func betterOptimizedConcurrentReadAndSum(filePath string) (float64, float64, int64) {
 	const bufferSize = 4096
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan string, 100)
 	results := make(chan [3]float64, 100)
 	done := make(chan bool)
 
 	worker := func() {
+		tl := track("worker", phaseWait)
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-			y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseParse)
+			x, y, ok := parseLineString(line)
+			if !ok {
+				tl.enter(phaseWait)
+				continue // Skip malformed lines
+			}
+			tl.enter(phaseSend)
 			results <- [3]float64{x, y, 1}
+			tl.enter(phaseWait)
 		}
+		tl.done()
 		done <- true
 	}
 
@@ -267,6 +306,7 @@ func betterOptimizedConcurrentReadAndSum(filePath string) (float64, float64, int
 
 	// Read file and send lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, bufferSize)
 		line := ""
 		for {
@@ -276,13 +316,16 @@ func betterOptimizedConcurrentReadAndSum(filePath string) (float64, float64, int
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(lines)
 				return
 			}
 			data := string(buffer[:n])
 			for _, char := range data {
 				if char == '\n' {
+					tl.enter(phaseSend)
 					lines <- line
+					tl.enter(phaseRead)
 					line = ""
 				} else {
 					line += string(char)
@@ -290,8 +333,10 @@ func betterOptimizedConcurrentReadAndSum(filePath string) (float64, float64, int
 			}
 		}
 		if len(line) > 0 {
+			tl.enter(phaseSend)
 			lines <- line
 		}
+		tl.done()
 		close(lines)
 	}()
 
@@ -315,12 +360,15 @@ func betterOptimizedConcurrentReadAndSum(filePath string) (float64, float64, int
 }
 
 func streamingReadAndSum(filePath string) (float64, float64, int64) {
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan string)       // Channel to stream lines to workers
 	results := make(chan [3]float64) // Channel for results
@@ -328,15 +376,19 @@ func streamingReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Worker function to process lines
 	worker := func() {
+		tl := track("worker", phaseWait)
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			if commaIdx == -1 {
+			tl.enter(phaseParse)
+			x, y, ok := parseLineString(line)
+			if !ok {
+				tl.enter(phaseWait)
 				continue // Skip malformed lines
 			}
-			x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-			y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseSend)
 			results <- [3]float64{x, y, 1}
+			tl.enter(phaseWait)
 		}
+		tl.done()
 		done <- struct{}{} // Signal that the worker is done
 	}
 
@@ -348,6 +400,7 @@ func streamingReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Goroutine to read the file line by line and send lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, 1024) // Small buffer for efficient reads
 		line := ""                   // Line accumulator
 		for {
@@ -357,6 +410,7 @@ func streamingReadAndSum(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(lines)
 				return
 			}
@@ -365,7 +419,9 @@ func streamingReadAndSum(filePath string) (float64, float64, int64) {
 			data := string(buffer[:n])
 			for _, char := range data {
 				if char == '\n' {
+					tl.enter(phaseSend)
 					lines <- line
+					tl.enter(phaseRead)
 					line = ""
 				} else {
 					line += string(char)
@@ -375,8 +431,10 @@ func streamingReadAndSum(filePath string) (float64, float64, int64) {
 
 		// Handle the last line if it doesn't end with a newline
 		if len(line) > 0 {
+			tl.enter(phaseSend)
 			lines <- line
 		}
+		tl.done()
 		close(lines) // Signal that no more lines will be sent
 	}()
 
@@ -403,12 +461,15 @@ func streamingReadAndSum(filePath string) (float64, float64, int64) {
 
 func optimizedStreamingReadAndSum(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536 // Large buffer for efficient file reading
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan []string, 10) // Channel for batches of lines
 	results := make(chan [3]float64) // Channel for aggregated results
@@ -419,22 +480,25 @@ func optimizedStreamingReadAndSum(filePath string) (float64, float64, int64) {
 		var localSumX, localSumY float64
 		var localLines int64
 
+		tl := track("worker", phaseWait)
 		for batch := range lines {
+			tl.enter(phaseParse)
 			for _, line := range batch {
-				commaIdx := strings.Index(line, ",")
-				if commaIdx == -1 {
+				x, y, ok := parseLineString(line)
+				if !ok {
 					continue // Skip malformed lines
 				}
-				x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-				y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
+			tl.enter(phaseWait)
 		}
 
 		// Send aggregated results for this worker
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
+		tl.done()
 		done <- struct{}{} // Signal that the worker is done
 	}
 
@@ -446,6 +510,7 @@ func optimizedStreamingReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Read file in chunks and send batches of lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, bufferSize)
 		line := ""
 		batch := []string{}
@@ -457,6 +522,7 @@ func optimizedStreamingReadAndSum(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(lines)
 				return
 			}
@@ -469,7 +535,9 @@ func optimizedStreamingReadAndSum(filePath string) (float64, float64, int64) {
 
 					// Send a batch of 100 lines to workers
 					if len(batch) >= 100 {
+						tl.enter(phaseSend)
 						lines <- batch
+						tl.enter(phaseRead)
 						batch = []string{}
 					}
 				} else {
@@ -483,8 +551,10 @@ func optimizedStreamingReadAndSum(filePath string) (float64, float64, int64) {
 			batch = append(batch, line)
 		}
 		if len(batch) > 0 {
+			tl.enter(phaseSend)
 			lines <- batch
 		}
+		tl.done()
 		close(lines) // Signal that no more batches will be sent
 	}()
 
@@ -541,10 +611,7 @@ func optimizedReadAndSum(filePath string) (float64, float64, int64) {
 		for _, char := range data {
 			if char == '\n' {
 				// Process the completed line
-				commaIdx := strings.Index(line, ",")
-				if commaIdx != -1 {
-					x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-					y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+				if x, y, ok := parseLineString(line); ok {
 					totalSumX += x
 					totalSumY += y
 					totalLines++
@@ -558,10 +625,7 @@ func optimizedReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Process the last line if it doesn't end with a newline
 	if len(line) > 0 {
-		commaIdx := strings.Index(line, ",")
-		if commaIdx != -1 {
-			x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-			y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+		if x, y, ok := parseLineString(line); ok {
 			totalSumX += x
 			totalSumY += y
 			totalLines++
@@ -574,12 +638,15 @@ func optimizedReadAndSum(filePath string) (float64, float64, int64) {
 func fastReadAndSumWithChannels(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536 // Large buffer for efficient reading
 	const batchSize = 100    // Number of lines per batch
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan []string, 10) // Channel for batches of lines
 	results := make(chan [3]float64) // Channel for aggregated results
@@ -590,22 +657,25 @@ func fastReadAndSumWithChannels(filePath string) (float64, float64, int64) {
 		var localSumX, localSumY float64
 		var localLineCount int64
 
+		tl := track("worker", phaseWait)
 		for batch := range lines {
+			tl.enter(phaseParse)
 			for _, line := range batch {
-				commaIdx := strings.Index(line, ",")
-				if commaIdx == -1 {
+				x, y, ok := parseLineString(line)
+				if !ok {
 					continue // Skip malformed lines
 				}
-				x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-				y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
 				localSumX += x
 				localSumY += y
 				localLineCount++
 			}
+			tl.enter(phaseWait)
 		}
 
 		// Send aggregated results
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLineCount)}
+		tl.done()
 		done <- struct{}{} // Signal completion
 	}
 
@@ -617,6 +687,7 @@ func fastReadAndSumWithChannels(filePath string) (float64, float64, int64) {
 
 	// Goroutine to read the file and send batches of lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, bufferSize)
 		line := ""
 		batch := []string{}
@@ -628,6 +699,7 @@ func fastReadAndSumWithChannels(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(lines)
 				return
 			}
@@ -638,7 +710,9 @@ func fastReadAndSumWithChannels(filePath string) (float64, float64, int64) {
 					batch = append(batch, line)
 					line = ""
 					if len(batch) >= batchSize {
+						tl.enter(phaseSend)
 						lines <- batch // Send a full batch
+						tl.enter(phaseRead)
 						batch = []string{}
 					}
 				} else {
@@ -652,9 +726,11 @@ func fastReadAndSumWithChannels(filePath string) (float64, float64, int64) {
 			batch = append(batch, line)
 		}
 		if len(batch) > 0 {
+			tl.enter(phaseSend)
 			lines <- batch
 		}
 
+		tl.done()
 		close(lines) // Signal no more lines
 	}()
 
@@ -709,14 +785,12 @@ func optimizedParsingAndSum(filePath string) (float64, float64, int64) {
 		data := append(leftover, buffer[:n]...)
 		lineStart = 0 // Reset lineStart for the combined data
 
+		clean := cleanChunk(data)
 		for i := 0; i < len(data); i++ {
 			if data[i] == '\n' {
 				// Parse the line
 				line := data[lineStart:i]
-				commaIdx := findComma(line)
-				if commaIdx != -1 {
-					x := parseFloat(line[:commaIdx])
-					y := parseFloat(line[commaIdx+1:])
+				if x, y, ok := parseChunkLine(line, clean); ok {
 					totalSumX += x
 					totalSumY += y
 					totalLines++
@@ -736,10 +810,7 @@ func optimizedParsingAndSum(filePath string) (float64, float64, int64) {
 	// Process any remaining line if the file doesn't end with a newline
 	if len(leftover) > 0 {
 		line := leftover
-		commaIdx := findComma(line)
-		if commaIdx != -1 {
-			x := parseFloat(line[:commaIdx])
-			y := parseFloat(line[commaIdx+1:])
+		if x, y, ok := parseChunkLine(line, cleanChunk(line)); ok {
 			totalSumX += x
 			totalSumY += y
 			totalLines++
@@ -811,14 +882,12 @@ func optimizedParsingWithPointers(filePath string) (float64, float64, int64) {
 		data := append(leftover, buffer[:n]...)
 		*lineStart = 0 // Reset lineStart for the combined data
 
+		clean := cleanChunk(data)
 		for i := 0; i < len(data); i++ {
 			if data[i] == '\n' {
 				// Parse the line
 				line := data[*lineStart:i]
-				commaIdx := findComma(line)
-				if commaIdx != -1 {
-					x := parseFloat(line[:commaIdx])
-					y := parseFloat(line[commaIdx+1:])
+				if x, y, ok := parseChunkLine(line, clean); ok {
 					totalSumX += x
 					totalSumY += y
 					totalLines++
@@ -838,10 +907,7 @@ func optimizedParsingWithPointers(filePath string) (float64, float64, int64) {
 	// Process any remaining line if the file doesn't end with a newline
 	if len(leftover) > 0 {
 		line := leftover
-		commaIdx := findComma(line)
-		if commaIdx != -1 {
-			x := parseFloat(line[:commaIdx])
-			y := parseFloat(line[commaIdx+1:])
+		if x, y, ok := parseChunkLine(line, cleanChunk(line)); ok {
 			totalSumX += x
 			totalSumY += y
 			totalLines++
@@ -880,15 +946,13 @@ func combinedOptimizedParsing(filePath string) (float64, float64, int64) {
 		data := append(leftover, buffer[:n]...)
 		lineStart = 0 // Reset lineStart for the combined data
 
+		clean := cleanChunk(data)
 		for i := 0; i < len(data); i++ {
 			if data[i] == '\n' {
 				// Parse the line
 				if lineStart < i { // Ensure valid range for slicing
 					line := data[lineStart:i]
-					commaIdx := findComma(line)
-					if commaIdx != -1 {
-						x := parseFloat(line[:commaIdx])
-						y := parseFloat(line[commaIdx+1:])
+					if x, y, ok := parseChunkLine(line, clean); ok {
 						totalSumX += x
 						totalSumY += y
 						totalLines++
@@ -908,10 +972,7 @@ func combinedOptimizedParsing(filePath string) (float64, float64, int64) {
 
 	// Process any remaining line if the file doesn't end with a newline
 	if len(leftover) > 0 {
-		commaIdx := findComma(leftover)
-		if commaIdx != -1 {
-			x := parseFloat(leftover[:commaIdx])
-			y := parseFloat(leftover[commaIdx+1:])
+		if x, y, ok := parseChunkLine(leftover, cleanChunk(leftover)); ok {
 			totalSumX += x
 			totalSumY += y
 			totalLines++
@@ -930,37 +991,183 @@ func findComma(line []byte) int {
 	return -1
 }
 
-func parseFloat(b []byte) float64 {
-	var result float64
-	var decimalPlace float64 = 1
-	var isFraction bool
+// utf8BOM is the byte order mark some editors put at the start of a file.
+const utf8BOM = "\xEF\xBB\xBF"
 
-	for _, c := range b {
-		if c == '.' {
-			isFraction = true
-			continue
+// trimLine strips a trailing '\r' left by CRLF line endings and a leading
+// UTF-8 BOM, which can only show up on the first line of a file.
+// For clean '\n'-only input this is two byte comparisons per line.
+func trimLine(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	if len(line) >= 3 && line[0] == utf8BOM[0] && line[1] == utf8BOM[1] && line[2] == utf8BOM[2] {
+		line = line[3:]
+	}
+	return line
+}
+
+// trimField strips the spaces and tabs surrounding a field, e.g. "1.5, 2.5".
+func trimField(b []byte) []byte {
+	for len(b) > 0 && (b[0] == ' ' || b[0] == '\t') {
+		b = b[1:]
+	}
+	for len(b) > 0 && (b[len(b)-1] == ' ' || b[len(b)-1] == '\t') {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// splitLine normalizes a single line and returns its two fields.
+// ok is false when the line has no comma.
+func splitLine(line []byte) (a, b []byte, ok bool) {
+	line = trimLine(line)
+	commaIdx := findComma(line)
+	if commaIdx == -1 {
+		return nil, nil, false
+	}
+	return trimField(line[:commaIdx]), trimField(line[commaIdx+1:]), true
+}
+
+// cleanChunk tells from the first line of a chunk whether the file was
+// exported with a BOM, CRLF line endings or spaces around the fields. It is
+// checked once per chunk: the lines of a clean chunk go to parseLine, those of
+// any other chunk straight to parseLineNormalized.
+func cleanChunk(data []byte) bool {
+	if bytes.HasPrefix(data, []byte(utf8BOM)) {
+		return false
+	}
+	first := data
+	if end := bytes.IndexByte(data, '\n'); end != -1 {
+		first = data[:end]
+	}
+	for _, c := range first {
+		if c == '\r' || c == ' ' || c == '\t' {
+			return false
 		}
-		if c >= '0' && c <= '9' {
-			digit := float64(c - '0')
-			if isFraction {
-				decimalPlace /= 10
-				result += digit * decimalPlace
-			} else {
-				result = result*10 + digit
+	}
+	return true
+}
+
+// parseChunkLine parses a line of a chunk that cleanChunk has looked at.
+func parseChunkLine(line []byte, clean bool) (x, y float64, ok bool) {
+	if clean {
+		return parseLine(line)
+	}
+	return parseLineNormalized(line)
+}
+
+// parseLine parses both fields of a single line. Clean lines of plain
+// decimals, as the generator writes them, take one scan for the comma and
+// one digit loop per field; anything else, such as an exponent or a stray
+// '\r' or space in an otherwise clean file, falls back to
+// parseLineNormalized.
+// ok is false when the line has no comma or a field is not a number, e.g.
+// a header line, so that such lines are skipped rather than counted.
+func parseLine(line []byte) (x, y float64, ok bool) {
+	if commaIdx := findComma(line); commaIdx != -1 {
+		x, okX := parsePlain(line[:commaIdx])
+		y, okY := parsePlain(line[commaIdx+1:])
+		if okX && okY {
+			return x, y, true
+		}
+	}
+	return parseLineNormalized(line)
+}
+
+// parseLineNormalized strips a '\r', a BOM and the spaces around the fields
+// before parsing them with parseDecimal.
+func parseLineNormalized(line []byte) (x, y float64, ok bool) {
+	a, b, ok := splitLine(line)
+	if !ok {
+		return 0, 0, false
+	}
+	x, okX := parseDecimal(a, '.')
+	y, okY := parseDecimal(b, '.')
+	return x, y, okX && okY
+}
+
+// parsePlain parses [-]digits[.digits] of at most 15 characters, so that the
+// digits form an integer below 2^53 and dividing it by a power of ten rounds
+// exactly like strconv.ParseFloat. ok is false for anything else.
+func parsePlain(b []byte) (float64, bool) {
+	neg := len(b) > 0 && b[0] == '-'
+	if neg {
+		b = b[1:]
+	}
+	if len(b) > 15 {
+		return 0, false
+	}
+
+	// The generator writes d.dd: the digits are a count of hundredths, taken
+	// without looking for the point.
+	if n := len(b); n >= 4 && b[n-3] == '.' && b[n-2]-'0' <= 9 && b[n-1]-'0' <= 9 {
+		var hundredths uint64
+		i := 0
+		for ; i < n-3 && b[i]-'0' <= 9; i++ {
+			hundredths = hundredths*10 + uint64(b[i]-'0')
+		}
+		if i == n-3 {
+			f := float64(hundredths*100+uint64(b[n-2]-'0')*10+uint64(b[n-1]-'0')) / 100
+			if neg {
+				f = -f
+			}
+			return f, true
+		}
+	}
+
+	var mantissa uint64
+	i := 0
+	for ; i < len(b) && b[i]-'0' <= 9; i++ {
+		mantissa = mantissa*10 + uint64(b[i]-'0')
+	}
+	f := float64(mantissa)
+	if i < len(b) {
+		if b[i] != '.' || len(b) == 1 {
+			return 0, false // Not a digit, or a lone point
+		}
+		for _, c := range b[i+1:] {
+			if c-'0' > 9 {
+				return 0, false
 			}
+			mantissa = mantissa*10 + uint64(c-'0')
 		}
+		f = float64(mantissa) / pow10[len(b)-1-i]
+	} else if i == 0 {
+		return 0, false
 	}
-	return result
+
+	if neg {
+		f = -f
+	}
+	return f, true
+}
+
+// parseLineString is the strconv based counterpart of parseLine used by the
+// line-per-string strategies.
+func parseLineString(line string) (x, y float64, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = strings.TrimPrefix(line, utf8BOM)
+	commaIdx := strings.Index(line, ",")
+	if commaIdx == -1 {
+		return 0, 0, false
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(line[:commaIdx]), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(line[commaIdx+1:]), 64)
+	return x, y, errX == nil && errY == nil
 }
 
 func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536 // Large buffer for efficient reading
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	chunks := make(chan []byte, 10)  // Channel for file chunks
 	results := make(chan [3]float64) // Channel for worker results
@@ -971,16 +1178,16 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 		var localSumX, localSumY float64
 		var localLines int64
 
+		tl := track("worker", phaseWait)
 		for chunk := range chunks {
+			tl.enter(phaseParse)
 			lineStart := 0
+			clean := cleanChunk(chunk)
 			for i := 0; i < len(chunk); i++ {
 				if chunk[i] == '\n' {
 					// Parse the line
 					line := chunk[lineStart:i]
-					commaIdx := findComma(line)
-					if commaIdx != -1 {
-						x := parseFloat(line[:commaIdx])
-						y := parseFloat(line[commaIdx+1:])
+					if x, y, ok := parseChunkLine(line, clean); ok {
 						localSumX += x
 						localSumY += y
 						localLines++
@@ -995,10 +1202,13 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 			} else {
 				chunk = nil
 			}
+			tl.enter(phaseWait)
 		}
 
 		// Send local results
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
+		tl.done()
 		done <- struct{}{}
 	}
 
@@ -1010,6 +1220,7 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 
 	// Goroutine to read file and send chunks to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, bufferSize)
 		leftover := make([]byte, 0, bufferSize)
 
@@ -1020,6 +1231,7 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(chunks)
 				return
 			}
@@ -1039,7 +1251,9 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 
 			if lastNewline != -1 {
 				// Send complete lines to workers
+				tl.enter(phaseSend)
 				chunks <- chunk[:lastNewline+1]
+				tl.enter(phaseRead)
 				// Save leftover partial line
 				leftover = append([]byte{}, chunk[lastNewline+1:]...)
 			} else {
@@ -1050,8 +1264,10 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 
 		// Handle leftover as the final line
 		if len(leftover) > 0 {
+			tl.enter(phaseSend)
 			chunks <- leftover
 		}
+		tl.done()
 
 		close(chunks) // Signal no more chunks
 	}()
@@ -1079,12 +1295,15 @@ func optimizedParsingWithChannels(filePath string) (float64, float64, int64) {
 
 func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536 // Large buffer for efficient reading
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	chunks := make(chan []byte, 10)  // Channel for file chunks
 	results := make(chan [3]float64) // Channel for aggregated results
@@ -1095,16 +1314,16 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 		var localSumX, localSumY float64
 		var localLines int64
 
+		tl := track("worker", phaseWait)
 		for chunk := range chunks {
+			tl.enter(phaseParse)
 			lineStart := 0
+			clean := cleanChunk(chunk)
 			for i := 0; i < len(chunk); i++ {
 				if chunk[i] == '\n' {
 					// Parse the line
 					line := chunk[lineStart:i]
-					commaIdx := findComma(line)
-					if commaIdx != -1 {
-						x := parseFloat(line[:commaIdx])
-						y := parseFloat(line[commaIdx+1:])
+					if x, y, ok := parseChunkLine(line, clean); ok {
 						localSumX += x
 						localSumY += y
 						localLines++
@@ -1116,12 +1335,16 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 			// Handle leftover partial line
 			if lineStart < len(chunk) {
 				leftover := append([]byte{}, chunk[lineStart:]...)
+				tl.enter(phaseSend)
 				chunks <- leftover // Carry over leftover to next chunk
 			}
+			tl.enter(phaseWait)
 		}
 
 		// Send aggregated results for this worker
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
+		tl.done()
 		done <- struct{}{}
 	}
 
@@ -1133,6 +1356,7 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 
 	// Goroutine to read file and send chunks to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		buffer := make([]byte, bufferSize)
 		var leftover []byte
 
@@ -1143,6 +1367,7 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 			}
 			if err != nil {
 				fmt.Println("Error reading file:", err)
+				tl.done()
 				close(chunks)
 				return
 			}
@@ -1162,7 +1387,9 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 
 			if lastNewline != -1 {
 				// Send complete lines to workers
+				tl.enter(phaseSend)
 				chunks <- chunk[:lastNewline+1]
+				tl.enter(phaseRead)
 				// Save leftover partial line
 				leftover = append([]byte{}, chunk[lastNewline+1:]...)
 			} else {
@@ -1173,8 +1400,10 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 
 		// Send any remaining leftover as the last line
 		if len(leftover) > 0 {
+			tl.enter(phaseSend)
 			chunks <- leftover
 		}
+		tl.done()
 		close(chunks) // Signal no more chunks
 	}()
 
@@ -1201,12 +1430,15 @@ func optimizedParsingWithChannels_2(filePath string) (float64, float64, int64) {
 
 func syncReadAndSum(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseRead)
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -1218,20 +1450,22 @@ func syncReadAndSum(filePath string) (float64, float64, int64) {
 
 	worker := func() {
 		defer wg.Done()
+		tl := track("worker", phaseWait)
+		defer tl.done()
 		var localSumX, localSumY float64
 		var localLines int64
 
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			if commaIdx != -1 {
-				x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-				y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseParse)
+			if x, y, ok := parseLineString(line); ok {
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
+			tl.enter(phaseWait)
 		}
 
+		tl.enter(phaseSend)
 		mu.Lock()
 		totalSumX += localSumX
 		totalSumY += localSumY
@@ -1262,7 +1496,9 @@ func syncReadAndSum(filePath string) (float64, float64, int64) {
 		data := string(buffer[:n])
 		for _, char := range data {
 			if char == '\n' {
+				tl.enter(phaseSend)
 				lines <- line
+				tl.enter(phaseRead)
 				line = ""
 			} else {
 				line += string(char)
@@ -1271,9 +1507,11 @@ func syncReadAndSum(filePath string) (float64, float64, int64) {
 	}
 
 	if len(line) > 0 {
+		tl.enter(phaseSend)
 		lines <- line
 	}
 	close(lines)
+	tl.enter(phaseWait)
 	wg.Wait()
 
 	return totalSumX, totalSumY, totalLines
@@ -1293,10 +1531,7 @@ func bufioReadAndSum(filePath string) (float64, float64, int64) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		commaIdx := strings.Index(line, ",")
-		if commaIdx != -1 {
-			x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-			y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+		if x, y, ok := parseLineString(line); ok {
 			totalSumX += x
 			totalSumY += y
 			totalLines++
@@ -1312,12 +1547,15 @@ func bufioReadAndSum(filePath string) (float64, float64, int64) {
 }
 
 func bufioWithSyncReadAndSum(filePath string) (float64, float64, int64) {
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseRead)
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -1329,20 +1567,22 @@ func bufioWithSyncReadAndSum(filePath string) (float64, float64, int64) {
 
 	worker := func() {
 		defer wg.Done()
+		tl := track("worker", phaseWait)
+		defer tl.done()
 		var localSumX, localSumY float64
 		var localLines int64
 
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			if commaIdx != -1 {
-				x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-				y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseParse)
+			if x, y, ok := parseLineString(line); ok {
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
+			tl.enter(phaseWait)
 		}
 
+		tl.enter(phaseSend)
 		mu.Lock()
 		totalSumX += localSumX
 		totalSumY += localSumY
@@ -1358,10 +1598,13 @@ func bufioWithSyncReadAndSum(filePath string) (float64, float64, int64) {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		tl.enter(phaseSend)
 		lines <- scanner.Text()
+		tl.enter(phaseRead)
 	}
 
 	close(lines)
+	tl.enter(phaseWait)
 	wg.Wait()
 
 	if err := scanner.Err(); err != nil {
@@ -1373,12 +1616,15 @@ func bufioWithSyncReadAndSum(filePath string) (float64, float64, int64) {
 }
 
 func bufioWithChannelsReadAndSum(filePath string) (float64, float64, int64) {
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	lines := make(chan string, 100)  // Channel to pass lines to workers
 	results := make(chan [3]float64) // Channel for aggregated results
@@ -1389,19 +1635,21 @@ func bufioWithChannelsReadAndSum(filePath string) (float64, float64, int64) {
 		var localSumX, localSumY float64
 		var localLines int64
 
+		tl := track("worker", phaseWait)
 		for line := range lines {
-			commaIdx := strings.Index(line, ",")
-			if commaIdx != -1 {
-				x, _ := strconv.ParseFloat(line[:commaIdx], 64)
-				y, _ := strconv.ParseFloat(line[commaIdx+1:], 64)
+			tl.enter(phaseParse)
+			if x, y, ok := parseLineString(line); ok {
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
+			tl.enter(phaseWait)
 		}
 
 		// Send the local results to the results channel
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
+		tl.done()
 		done <- struct{}{} // Signal this worker is done
 	}
 
@@ -1413,10 +1661,14 @@ func bufioWithChannelsReadAndSum(filePath string) (float64, float64, int64) {
 
 	// Goroutine to read file line by line and send lines to workers
 	go func() {
+		tl := track("reader", phaseRead)
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
+			tl.enter(phaseSend)
 			lines <- scanner.Text()
+			tl.enter(phaseRead)
 		}
+		tl.done()
 		close(lines) // Close the lines channel when done
 	}()
 
@@ -1443,23 +1695,32 @@ func bufioWithChannelsReadAndSum(filePath string) (float64, float64, int64) {
 
 func optimizedParsingWithReadAt(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536 // Large buffer for efficient reading
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	stat, _ := file.Stat()
 	fileSize := stat.Size()
 	numWorkers := 4
-	chunkSize := fileSize / int64(numWorkers)
+	chunks, err := splitChunks(file, 0, fileSize, numWorkers)
+	if err != nil {
+		fmt.Println("Error splitting file:", err)
+		return 0, 0, 0
+	}
 
 	results := make(chan [3]float64, numWorkers)
 	var wg sync.WaitGroup
 
 	worker := func(offset, size int64) {
 		defer wg.Done()
+		tl := track("worker", phaseRead)
+		defer tl.done()
 		buffer := make([]byte, size)
 		_, err := file.ReadAt(buffer, offset)
 		if err != nil && err != io.EOF {
@@ -1467,17 +1728,16 @@ func optimizedParsingWithReadAt(filePath string) (float64, float64, int64) {
 			return
 		}
 
+		tl.enter(phaseParse)
 		var localSumX, localSumY float64
 		var localLines int64
 
 		lineStart := 0
+		clean := cleanChunk(buffer)
 		for i := 0; i < len(buffer); i++ {
 			if buffer[i] == '\n' {
 				line := buffer[lineStart:i]
-				commaIdx := findComma(line)
-				if commaIdx != -1 {
-					x := parseFloat(line[:commaIdx])
-					y := parseFloat(line[commaIdx+1:])
+				if x, y, ok := parseChunkLine(line, clean); ok {
 					localSumX += x
 					localSumY += y
 					localLines++
@@ -1489,28 +1749,21 @@ func optimizedParsingWithReadAt(filePath string) (float64, float64, int64) {
 		// Handle leftover line in the chunk
 		if lineStart < len(buffer) {
 			line := buffer[lineStart:]
-			commaIdx := findComma(line)
-			if commaIdx != -1 {
-				x := parseFloat(line[:commaIdx])
-				y := parseFloat(line[commaIdx+1:])
+			if x, y, ok := parseChunkLine(line, clean); ok {
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
 		}
 
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
 	}
 
-	// Spawn workers to process file chunks
-	for i := 0; i < numWorkers; i++ {
-		offset := int64(i) * chunkSize
-		size := chunkSize
-		if i == numWorkers-1 { // Last worker takes remaining bytes
-			size += fileSize % int64(numWorkers)
-		}
+	// Spawn workers to process line-aligned file chunks
+	for _, c := range chunks {
 		wg.Add(1)
-		go worker(offset, size)
+		go worker(c.offset, c.size)
 	}
 
 	go func() {
@@ -1531,25 +1784,47 @@ func optimizedParsingWithReadAt(filePath string) (float64, float64, int64) {
 	return totalSumX, totalSumY, totalLines
 }
 
+// Approximate implementation reading only a sample of line-aligned blocks
+// with ReadAt. The sums are the estimated means times the estimated number
+// of lines, see estimateBySampling.
+func sampledParsingWithReadAt(filePath string) (float64, float64, int64) {
+	est, err := estimateBySampling(filePath, formats[0], 256, 65536, 0, 1) // A fixed seed, so that runs are comparable
+	if err != nil {
+		fmt.Println("Error sampling file:", err)
+		return 0, 0, 0
+	}
+
+	return est.meanX * float64(est.lines), est.meanY * float64(est.lines), est.lines
+}
+
 func optimizedParsingWithReadAtEnhanced(filePath string) (float64, float64, int64) {
 	//const bufferSize = 65536
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	stat, _ := file.Stat()
 	fileSize := stat.Size()
 	numWorkers := runtime.NumCPU()
-	chunkSize := fileSize / int64(numWorkers)
+	chunks, err := splitChunks(file, 0, fileSize, numWorkers)
+	if err != nil {
+		fmt.Println("Error splitting file:", err)
+		return 0, 0, 0
+	}
 
 	results := make(chan [3]float64, numWorkers)
 	var wg sync.WaitGroup
 
 	worker := func(offset, size int64) {
 		defer wg.Done()
+		tl := track("worker", phaseRead)
+		defer tl.done()
 		buffer := make([]byte, size)
 		_, err := file.ReadAt(buffer, offset)
 		if err != nil && err != io.EOF {
@@ -1557,17 +1832,16 @@ func optimizedParsingWithReadAtEnhanced(filePath string) (float64, float64, int6
 			return
 		}
 
+		tl.enter(phaseParse)
 		var localSumX, localSumY float64
 		var localLines int64
 
 		lineStart := 0
+		clean := cleanChunk(buffer)
 		for i := 0; i < len(buffer); i++ {
 			if buffer[i] == '\n' {
 				line := buffer[lineStart:i]
-				commaIdx := findComma(line)
-				if commaIdx != -1 {
-					x := parseFloat(line[:commaIdx])
-					y := parseFloat(line[commaIdx+1:])
+				if x, y, ok := parseChunkLine(line, clean); ok {
 					localSumX += x
 					localSumY += y
 					localLines++
@@ -1578,27 +1852,20 @@ func optimizedParsingWithReadAtEnhanced(filePath string) (float64, float64, int6
 
 		if lineStart < len(buffer) {
 			line := buffer[lineStart:]
-			commaIdx := findComma(line)
-			if commaIdx != -1 {
-				x := parseFloat(line[:commaIdx])
-				y := parseFloat(line[commaIdx+1:])
+			if x, y, ok := parseChunkLine(line, clean); ok {
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
 		}
 
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
 	}
 
-	for i := 0; i < numWorkers; i++ {
-		offset := int64(i) * chunkSize
-		size := chunkSize
-		if i == numWorkers-1 {
-			size += fileSize % int64(numWorkers)
-		}
+	for _, c := range chunks {
 		wg.Add(1)
-		go worker(offset, size)
+		go worker(c.offset, c.size)
 	}
 
 	go func() {
@@ -1619,23 +1886,32 @@ func optimizedParsingWithReadAtEnhanced(filePath string) (float64, float64, int6
 }
 func optimizedParsingWithReadAtAndBuffer(filePath string) (float64, float64, int64) {
 	const bufferSize = 65536
+	tl := track("main", phaseOpen)
+	defer tl.done()
 	file, err := os.Open(filePath)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return 0, 0, 0
 	}
 	defer file.Close()
+	tl.enter(phaseWait)
 
 	stat, _ := file.Stat()
 	fileSize := stat.Size()
 	numWorkers := runtime.NumCPU()
-	chunkSize := fileSize / int64(numWorkers)
+	chunks, err := splitChunks(file, 0, fileSize, numWorkers)
+	if err != nil {
+		fmt.Println("Error splitting file:", err)
+		return 0, 0, 0
+	}
 
 	results := make(chan [3]float64, numWorkers)
 	var wg sync.WaitGroup
 
 	worker := func(offset, size int64) {
 		defer wg.Done()
+		tl := track("worker", phaseRead)
+		defer tl.done()
 		buffer := make([]byte, bufferSize)
 		var localSumX, localSumY float64
 		var localLines int64
@@ -1649,24 +1925,24 @@ func optimizedParsingWithReadAtAndBuffer(filePath string) (float64, float64, int
 				toRead = int(size - bytesRead)
 			}
 
+			tl.enter(phaseRead)
 			n, err := file.ReadAt(buffer[:toRead], offset+bytesRead)
 			if err != nil && err != io.EOF {
 				fmt.Println("Error reading file chunk:", err)
 				return
 			}
 
+			tl.enter(phaseParse)
 			bytesRead += int64(n)
 			data := append(leftover, buffer[:n]...)
 			leftover = nil
 
 			lineStart := 0
+			clean := cleanChunk(data)
 			for i := 0; i < len(data); i++ {
 				if data[i] == '\n' {
 					line := data[lineStart:i]
-					commaIdx := findComma(line)
-					if commaIdx != -1 {
-						x := parseFloat(line[:commaIdx])
-						y := parseFloat(line[commaIdx+1:])
+					if x, y, ok := parseChunkLine(line, clean); ok {
 						localSumX += x
 						localSumY += y
 						localLines++
@@ -1681,27 +1957,20 @@ func optimizedParsingWithReadAtAndBuffer(filePath string) (float64, float64, int
 		}
 
 		if len(leftover) > 0 {
-			commaIdx := findComma(leftover)
-			if commaIdx != -1 {
-				x := parseFloat(leftover[:commaIdx])
-				y := parseFloat(leftover[commaIdx+1:])
+			if x, y, ok := parseChunkLine(leftover, cleanChunk(leftover)); ok {
 				localSumX += x
 				localSumY += y
 				localLines++
 			}
 		}
 
+		tl.enter(phaseSend)
 		results <- [3]float64{localSumX, localSumY, float64(localLines)}
 	}
 
-	for i := 0; i < numWorkers; i++ {
-		offset := int64(i) * chunkSize
-		size := chunkSize
-		if i == numWorkers-1 {
-			size += fileSize % int64(numWorkers)
-		}
+	for _, c := range chunks {
 		wg.Add(1)
-		go worker(offset, size)
+		go worker(c.offset, c.size)
 	}
 
 	go func() {
@@ -1744,66 +2013,167 @@ func parse() (float64, float64, int64) {
 
 }
 
+// compFloat reports whether f1 and f2 round to the same hundredths.
 func compFloat(f1 float64, f2 float64) bool {
-	i1 := int64(f1*100 + 0.5)
-	i2 := int64(f1*100 + 0.5)
+	i1 := int64(math.Round(f1 * 100))
+	i2 := int64(math.Round(f2 * 100))
 	return i1 == i2
 }
 
-func run() time.Duration {
-	start := time.Now()
-	s1, s2, lines := parse()
-	elapsed := time.Since(start)
+// verifyPath is where the generator writes the expected results for an
+// input file: points-verify.txt for points.txt, points-verify.bin.txt for
+// points.bin.
+func verifyPath(input string) string {
+	ext := filepath.Ext(input)
+	base := strings.TrimSuffix(input, ext)
+	if ext == ".txt" {
+		return base + "-verify.txt"
+	}
+	return base + "-verify" + ext + ".txt"
+}
 
-	data, err := os.ReadFile("points-verify.txt")
+// verify compares the results of a strategy on input with verifyPath(input).
+func verify(input string, s1, s2 float64, lines int64) error {
+	data, err := os.ReadFile(verifyPath(input))
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	parts := strings.Split(string(data[:len(data)-1]), ",")
 	vl, err := strconv.ParseInt(parts[2], 10, 64)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	f1, err := strconv.ParseFloat(parts[0], 8)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	f2, err := strconv.ParseFloat(parts[1], 8)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	if lines != vl {
-		panic(fmt.Sprintf("Expected number of lines to be: %d got %d\n", vl, lines))
+		return fmt.Errorf("Expected number of lines to be: %d got %d", vl, lines)
 	}
 
 	if !compFloat(s1, f1) {
-		panic(fmt.Sprintf("Expected first number to be: %.2f got %.2f\n", f1, s1))
+		return fmt.Errorf("Expected first number to be: %.2f got %.2f", f1, s1)
 	}
 
 	// if fmt.Sprintf("%.2f", s2) != fmt.Sprintf("%.2f", f2) {
 	if !compFloat(s2, f2) {
-		panic(fmt.Sprintf("Expected second number to be: %.2f got %.2f\n", f2, s2))
+		return fmt.Errorf("Expected second number to be: %.2f got %.2f", f2, s2)
 	}
 
-	return elapsed
+	return nil
+}
+
+func run(fi functionInfo) (time.Duration, float64, float64, int64) {
+	start := time.Now()
+	s1, s2, lines := fi.function(fi.file)
+	elapsed := time.Since(start)
+	return elapsed, s1, s2, lines
 }
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	//runAllFunctionsAndMeasureAvg(5, "text", cacheWarm)
 
-	//runAllFunctionsAndMeasureAvg(5)
+	output := flag.String("output", "text", "text prints each new best time; json or csv print a result record for it")
+	strategyName := flag.String("strategy", "parse", "strategy to repeat; parse is the parse function in main.go")
+	runs := flag.Int("runs", 0, "stop after this many runs (0 runs forever)")
+	cache := flag.String("cache", cacheWarm, "warm, or cold to evict the input from the page cache before every run")
+	profiles := flag.String("profile", "", "comma separated profiles to capture: cpu, heap, trace and timeline")
+	profileIteration := flag.Int("profile-iteration", 1, "iteration to profile, counting from 1; it is left out of the timings")
+	profileDir := flag.String("profile-dir", ".", "directory for the profiles")
+	flag.Parse()
+
+	fi, err := findStrategy(*strategyName)
+	if err == nil {
+		err = checkCacheMode(*cache, false)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+	var w *resultWriter
+	if *output != "text" {
+		if w, err = newResultWriter(*output); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(2)
+		}
+	}
+	var prof *profiler
+	if *profiles != "" {
+		if prof, err = newProfiler(*profiles, *profileDir, *profileIteration); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(2)
+		}
+	}
 
 	bestTime, err := time.ParseDuration("1h")
 	if err != nil {
 		panic(err)
 	}
 
-	for true {
-		execTime := run()
-		if execTime.Milliseconds() < bestTime.Milliseconds() {
+	var durations []time.Duration
+	for i := 0; *runs == 0 || i < *runs; i++ {
+		if *cache == cacheCold {
+			if err := evictFile(fi.file); err != nil {
+				panic(err)
+			}
+		}
+		if prof != nil && i+1 == prof.iteration {
+			// Profiling slows the iteration down, so it does not count.
+			stop, err := prof.start(fi.name)
+			if err != nil {
+				panic(err)
+			}
+			run(fi)
+			files, err := stop()
+			if err != nil {
+				panic(err)
+			}
+			fmt.Fprintf(os.Stderr, "Profiled iteration %d: %s\n", i+1, strings.Join(files, ", "))
+			continue
+		}
+
+		execTime, s1, s2, lines := run(fi)
+		durations = append(durations, execTime)
+
+		if w == nil {
+			if !fi.approximate() {
+				if err := verify(fi.file, s1, s2, lines); err != nil {
+					panic(err)
+				}
+			}
+			if execTime.Milliseconds() < bestTime.Milliseconds() {
+				bestTime = execTime
+				fmt.Printf("Execution time: %s\n", bestTime)
+			}
+			continue
+		}
+
+		// The timing statistics cover all runs so far. A record is written
+		// for every new best time, for a failed verification, which ends
+		// the loop, and after the last of -runs.
+		isBest := execTime < bestTime
+		if isBest {
 			bestTime = execTime
-			fmt.Printf("Execution time: %s\n", bestTime)
+		}
+		r := newRunResult(fi, *cache, s1, s2, lines, durations)
+		if isBest || r.Verification == verificationFailed || i == *runs-1 {
+			if err := w.write(r); err != nil {
+				panic(err)
+			}
+		}
+		if r.Verification == verificationFailed {
+			os.Exit(1)
 		}
 	}
 }
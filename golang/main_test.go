@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		x, y float64
+		ok   bool
+	}{
+		{"12.34,-56.78", 12.34, -56.78, true},
+		{"12.34,-56.78\r", 12.34, -56.78, true},
+		{"\xEF\xBB\xBF1.5,2.5", 1.5, 2.5, true},
+		{"1.5, 2.5", 1.5, 2.5, true},
+		{" -0.01 ,\t99.99 ", -0.01, 99.99, true},
+		{"1e-3,12345.678901", 1e-3, 12345.678901, true},
+		{"7,8", 7, 8, true},
+		{"x,y", 0, 0, false},
+		{"1.5,abc", 0, 0, false},
+		{"abc,1.5", 0, 0, false},
+		{"1.5", 0, 0, false},
+		{",", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		line := []byte(tt.line)
+		x, y, ok := parseLine(line)
+		if ok != tt.ok || ok && (x != tt.x || y != tt.y) {
+			t.Errorf("parseLine(%q) = %v, %v, %v, want %v, %v, %v", tt.line, x, y, ok, tt.x, tt.y, tt.ok)
+		}
+		x, y, ok = parseLineNormalized(line)
+		if ok != tt.ok || ok && (x != tt.x || y != tt.y) {
+			t.Errorf("parseLineNormalized(%q) = %v, %v, %v, want %v, %v, %v", tt.line, x, y, ok, tt.x, tt.y, tt.ok)
+		}
+		x, y, ok = parseLineString(tt.line)
+		if ok != tt.ok || ok && (x != tt.x || y != tt.y) {
+			t.Errorf("parseLineString(%q) = %v, %v, %v, want %v, %v, %v", tt.line, x, y, ok, tt.x, tt.y, tt.ok)
+		}
+	}
+
+	if x, _, ok := parseLine([]byte("NaN,1")); !ok || !math.IsNaN(x) {
+		t.Errorf("parseLine(%q) = %v, %v, want NaN", "NaN,1", x, ok)
+	}
+}
+
+func TestCleanChunk(t *testing.T) {
+	tests := []struct {
+		data  string
+		clean bool
+	}{
+		{"1.00,2.00\n3.00,4.00\n", true},
+		{"1.00,2.00", true},
+		{"", true},
+		{"1.00,2.00\r\n3.00,4.00\r\n", false},
+		{"\xEF\xBB\xBF1.00,2.00\n", false},
+		{"1.00, 2.00\n", false},
+		{"1.00,\t2.00\n", false},
+		{"1.00,2.00\n3.00, 4.00\n", true}, // Only the first line counts; parseLine falls back for the rest
+	}
+	for _, tt := range tests {
+		if clean := cleanChunk([]byte(tt.data)); clean != tt.clean {
+			t.Errorf("cleanChunk(%q) = %v, want %v", tt.data, clean, tt.clean)
+		}
+	}
+}
+
+func TestParsePlainMatchesStrconv(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		v := (rng.Float64() - 0.5) * math.Pow(10, float64(rng.Intn(12)))
+		s := strconv.FormatFloat(v, 'f', rng.Intn(8), 64)
+		want, err := strconv.ParseFloat(s, 64)
+		got, ok := parsePlain([]byte(s))
+		if len(s) > 16 || len(s) == 16 && s[0] != '-' {
+			if ok {
+				t.Errorf("parsePlain(%q) accepted a number longer than 15 characters", s)
+			}
+			continue
+		}
+		if err != nil || !ok || got != want {
+			t.Fatalf("parsePlain(%q) = %v, %v, strconv.ParseFloat = %v, %v", s, got, ok, want, err)
+		}
+	}
+
+	for h := -99999; h <= 99999; h++ { // Every value of the generator's domain, and then some
+		s := strconv.FormatFloat(float64(h)/100, 'f', 2, 64)
+		want, _ := strconv.ParseFloat(s, 64)
+		if got, ok := parsePlain([]byte(s)); !ok || got != want {
+			t.Fatalf("parsePlain(%q) = %v, %v, want %v", s, got, ok, want)
+		}
+	}
+
+	for _, s := range []string{"", "-", ".", "-.", "1.2.3", "1.2.34", "1e3", "+1", " 1", "1 ", "1\r", "NaN", "1x.25", "1.2x"} {
+		if v, ok := parsePlain([]byte(s)); ok {
+			t.Errorf("parsePlain(%q) = %v, want not ok", s, v)
+		}
+	}
+	for s, want := range map[string]float64{"5.": 5, ".5": 0.5, "-0.00": 0, "007.10": 7.1} {
+		if v, ok := parsePlain([]byte(s)); !ok || v != want {
+			t.Errorf("parsePlain(%q) = %v, %v, want %v", s, v, ok, want)
+		}
+	}
+}
+
+// chdirTemp changes to a new temporary directory for the rest of the test,
+// for the commands that read and write files in the current directory.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestVerify(t *testing.T) {
+	chdirTemp(t)
+	if err := os.WriteFile("points-verify.txt", []byte("-38823.24,49982976.65,1000001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sumX, sumY float64
+		lines      int64
+		ok         bool
+	}{
+		{-38823.24, 49982976.65, 1000001, true},
+		{-38823.23999999849, 49982976.64999844, 1000001, true}, // Rounding errors of the sums
+		{-38823.24, 49982976.65, 1000000, false},
+		{-38823.25, 49982976.65, 1000001, false},
+		{38823.24, 49982976.65, 1000001, false},
+		{-38823.24, 49982976.64, 1000001, false},
+		{-38823.24, 0, 1000001, false},
+	}
+	for _, tt := range tests {
+		err := verify("points.txt", tt.sumX, tt.sumY, tt.lines)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("verify(%v, %v, %d) = %v, want ok %v", tt.sumX, tt.sumY, tt.lines, err, tt.ok)
+		}
+	}
+
+	// points.bin has its own expected results
+	if err := verify("points.bin", -38823.24, 49982976.65, 1000001); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("verify of points.bin without points-verify.bin.txt = %v, want a missing file", err)
+	}
+	if err := os.WriteFile("points-verify.bin.txt", []byte("1.50,2.50,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify("points.bin", 1.5, 2.5, 2); err != nil {
+		t.Errorf("verify of points.bin = %v", err)
+	}
+}
+
+func TestVerifyPath(t *testing.T) {
+	for input, want := range map[string]string{
+		"points.txt":   "points-verify.txt",
+		"points.bin":   "points-verify.bin.txt",
+		"data/big.txt": "data/big-verify.txt",
+		"data/big.bin": "data/big-verify.bin.txt",
+		"points":       "points-verify.txt",
+	} {
+		if got := verifyPath(input); got != want {
+			t.Errorf("verifyPath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// messyPoints rewrites the points file at path the way files exported on
+// other systems look: a BOM, CRLF line endings and a space after the comma.
+func messyPoints(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	data = bytes.ReplaceAll(data, []byte(","), []byte(", "))
+	if err := os.WriteFile(path, append([]byte(utf8BOM), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		lines int
+		messy bool
+	}{
+		{3, false}, // Fewer bytes than workers
+		{50001, false},
+		{50001, true},
+	}
+	for _, tt := range tests {
+		lines := tt.lines
+		t.Run(fmt.Sprintf("%d lines messy %v", lines, tt.messy), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "points.txt")
+			xs, ys := writePoints(t, path, lines)
+			if tt.messy {
+				messyPoints(t, path)
+			}
+			var wantX, wantY float64
+			for i := range xs {
+				wantX += xs[i]
+				wantY += ys[i]
+			}
+
+			for _, fi := range strategies {
+				if fi.file != "points.txt" || fi.approximate() {
+					continue // Another input, or an estimate
+				}
+				sumX, sumY, n := fi.function(path)
+				if n != int64(lines) || math.Abs(sumX-wantX) > 1e-6 || math.Abs(sumY-wantY) > 1e-6 {
+					t.Errorf("%s = %.2f, %.2f, %d lines, want %.2f, %.2f, %d", fi.name, sumX, sumY, n, wantX, wantY, lines)
+				}
+			}
+		})
+	}
+}
+
+// baselineParseLine is the line parser the chunked strategies used before
+// CRLF, BOM, space and exponent support, kept to check that clean input did
+// not get slower. It ignores signs and rounds inexactly.
+func baselineParseLine(line []byte) (x, y float64, ok bool) {
+	commaIdx := findComma(line)
+	if commaIdx == -1 {
+		return 0, 0, false
+	}
+	return baselineParseFloat(line[:commaIdx]), baselineParseFloat(line[commaIdx+1:]), true
+}
+
+func baselineParseFloat(b []byte) float64 {
+	var result float64
+	var decimalPlace float64 = 1
+	var isFraction bool
+
+	for _, c := range b {
+		if c == '.' {
+			isFraction = true
+			continue
+		}
+		if c >= '0' && c <= '9' {
+			digit := float64(c - '0')
+			if isFraction {
+				decimalPlace /= 10
+				result += digit * decimalPlace
+			} else {
+				result = result*10 + digit
+			}
+		}
+	}
+	return result
+}
+
+// cleanLines are lines as the generator writes them by default.
+var cleanLines = [][]byte{
+	[]byte("-12.34,56.78"), []byte("99.99,99.99"), []byte("0.05,-7.10"),
+	[]byte("-99.99,0.00"), []byte("3.14,15.92"), []byte("-0.50,42.00"),
+}
+
+func benchmarkParseLine(b *testing.B, parse func([]byte) (float64, float64, bool)) {
+	var sumX, sumY float64
+	for i := 0; i < b.N; i++ {
+		x, y, _ := parse(cleanLines[i%len(cleanLines)])
+		sumX += x
+		sumY += y
+	}
+	if sumX == 1 && sumY == 1 {
+		b.Log(sumX, sumY) // Keeps the sums alive
+	}
+}
+
+// Compare with: go test -bench ParseLine -count 10 | benchstat
+func BenchmarkParseLine(b *testing.B)         { benchmarkParseLine(b, parseLine) }
+func BenchmarkParseLineBaseline(b *testing.B) { benchmarkParseLine(b, baselineParseLine) }
+
+// cleanChunkData is a 64KiB chunk of cleanLines, the buffer size of the
+// chunked strategies.
+func cleanChunkData() []byte {
+	var data []byte
+	for i := 0; len(data) < 65536; i++ {
+		data = append(data, cleanLines[i%len(cleanLines)]...)
+		data = append(data, '\n')
+	}
+	return data
+}
+
+var chunkSink float64
+
+// BenchmarkParseChunk parses a chunk the way the chunked strategies do,
+// including the cleanChunk check, for comparison with the baseline parser:
+// go test -bench ParseChunk -count 10 | benchstat
+func BenchmarkParseChunk(b *testing.B) {
+	data := cleanChunkData()
+	b.SetBytes(int64(len(data)))
+	var sum float64
+	for n := 0; n < b.N; n++ {
+		clean := cleanChunk(data)
+		lineStart := 0
+		for i := 0; i < len(data); i++ {
+			if data[i] == '\n' {
+				if x, y, ok := parseChunkLine(data[lineStart:i], clean); ok {
+					sum += x + y
+				}
+				lineStart = i + 1
+			}
+		}
+	}
+	chunkSink = sum
+}
+
+func BenchmarkParseChunkBaseline(b *testing.B) {
+	data := cleanChunkData()
+	b.SetBytes(int64(len(data)))
+	var sum float64
+	for n := 0; n < b.N; n++ {
+		lineStart := 0
+		for i := 0; i < len(data); i++ {
+			if data[i] == '\n' {
+				if x, y, ok := baselineParseLine(data[lineStart:i]); ok {
+					sum += x + y
+				}
+				lineStart = i + 1
+			}
+		}
+	}
+	chunkSink = sum
+}
@@ -2,61 +2,141 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"time"
 )
 
 type functionInfo struct {
 	name     string
-	function func() (float64, float64, int64)
+	file     string
+	function func(filePath string) (float64, float64, int64)
 }
 
-// The measuring function
-func runAllFunctionsAndMeasureAvg(n int) {
-	// List all parsing functions with their names
-	functions := []functionInfo{
-		{"vanillaReadAndSum", func() (float64, float64, int64) { return vanillaReadAndSum("points.txt") }},
-		{"concurrentReadAndSum", func() (float64, float64, int64) { return concurrentReadAndSum("points.txt") }},
-		{"optimizedConcurrentReadAndSum", func() (float64, float64, int64) { return optimizedConcurrentReadAndSum("points.txt") }},
-		{"betterOptimizedConcurrentReadAndSum", func() (float64, float64, int64) { return betterOptimizedConcurrentReadAndSum("points.txt") }},
-		{"streamingReadAndSum", func() (float64, float64, int64) { return streamingReadAndSum("points.txt") }},
-		{"optimizedStreamingReadAndSum", func() (float64, float64, int64) { return optimizedStreamingReadAndSum("points.txt") }},
-		{"optimizedReadAndSum", func() (float64, float64, int64) { return optimizedReadAndSum("points.txt") }},
-		{"fastReadAndSumWithChannels", func() (float64, float64, int64) { return fastReadAndSumWithChannels("points.txt") }},
-		{"bufioReadAndSum", func() (float64, float64, int64) { return bufioReadAndSum("points.txt") }},
-		{"bufioWithSyncReadAndSum", func() (float64, float64, int64) { return bufioWithSyncReadAndSum("points.txt") }},
-		{"bufioWithChannelsReadAndSum", func() (float64, float64, int64) { return bufioWithChannelsReadAndSum("points.txt") }},
-		{"syncReadAndSum", func() (float64, float64, int64) { return syncReadAndSum("points.txt") }},
-		{"optimizedParsingWithReadAt", func() (float64, float64, int64) { return optimizedParsingWithReadAt("points.txt") }},
-		{"optimizedParsingWithReadAtEnhanced", func() (float64, float64, int64) { return optimizedParsingWithReadAtEnhanced("points.txt") }},
-		{"optimizedParsingWithReadAtAndBuffer", func() (float64, float64, int64) { return optimizedParsingWithReadAtAndBuffer("points.txt") }},
-		{"optimizedParsingWithChannels", func() (float64, float64, int64) { return optimizedParsingWithChannels("points.txt") }},
-		{"optimizedParsingWithChannels_2", func() (float64, float64, int64) { return optimizedParsingWithChannels_2("points.txt") }},
-		{"optimizedParsingAndSum", func() (float64, float64, int64) { return optimizedParsingAndSum("points.txt") }},
-		{"combinedOptimizedParsing", func() (float64, float64, int64) { return combinedOptimizedParsing("points.txt") }},
+// strategies lists all parsing functions with their names and input files.
+var strategies = []functionInfo{
+	{"vanillaReadAndSum", "points.txt", vanillaReadAndSum},
+	{"concurrentReadAndSum", "points.txt", concurrentReadAndSum},
+	{"optimizedConcurrentReadAndSum", "points.txt", optimizedConcurrentReadAndSum},
+	{"betterOptimizedConcurrentReadAndSum", "points.txt", betterOptimizedConcurrentReadAndSum},
+	{"streamingReadAndSum", "points.txt", streamingReadAndSum},
+	{"optimizedStreamingReadAndSum", "points.txt", optimizedStreamingReadAndSum},
+	{"optimizedReadAndSum", "points.txt", optimizedReadAndSum},
+	{"fastReadAndSumWithChannels", "points.txt", fastReadAndSumWithChannels},
+	{"bufioReadAndSum", "points.txt", bufioReadAndSum},
+	{"bufioWithSyncReadAndSum", "points.txt", bufioWithSyncReadAndSum},
+	{"bufioWithChannelsReadAndSum", "points.txt", bufioWithChannelsReadAndSum},
+	{"syncReadAndSum", "points.txt", syncReadAndSum},
+	{"optimizedParsingWithReadAt", "points.txt", optimizedParsingWithReadAt},
+	{"sampledParsingWithReadAt", "points.txt", sampledParsingWithReadAt},
+	{"optimizedParsingWithReadAtEnhanced", "points.txt", optimizedParsingWithReadAtEnhanced},
+	{"optimizedParsingWithReadAtAndBuffer", "points.txt", optimizedParsingWithReadAtAndBuffer},
+	{"optimizedParsingWithChannels", "points.txt", optimizedParsingWithChannels},
+	{"optimizedParsingWithChannels_2", "points.txt", optimizedParsingWithChannels_2},
+	{"optimizedParsingAndSum", "points.txt", optimizedParsingAndSum},
+	{"combinedOptimizedParsing", "points.txt", combinedOptimizedParsing},
+	{"binaryReadAndSum", "points.bin", binaryReadAndSum},
+}
+
+// approximateStrategies are the strategies that estimate the sums instead of
+// computing them. They are timed like the others, but their results are not
+// checked against the verification file, which they would never match.
+var approximateStrategies = map[string]bool{"sampledParsingWithReadAt": true}
+
+func (fi functionInfo) approximate() bool {
+	return approximateStrategies[fi.name]
+}
+
+// parseStrategy is the parse function in main.go, the one the repetition
+// tester runs by default.
+var parseStrategy = functionInfo{"parse", "points.txt", func(string) (float64, float64, int64) { return parse() }}
+
+// findStrategy looks up a strategy by name; "parse" is parseStrategy.
+func findStrategy(name string) (functionInfo, error) {
+	if name == parseStrategy.name {
+		return parseStrategy, nil
+	}
+	for _, fi := range strategies {
+		if fi.name == name {
+			return fi, nil
+		}
+	}
+	return functionInfo{}, fmt.Errorf("unknown strategy %q", name)
+}
+
+// The measuring function. output is text for the original summary, or json
+// or csv for one result record per strategy and cache mode. cache is warm,
+// cold or both; cold results are named "<strategy> (cold)" in the summary.
+func runAllFunctionsAndMeasureAvg(n int, output string, cache string) error {
+	modes := []string{cache}
+	if cache == cacheBoth {
+		modes = []string{cacheCold, cacheWarm}
+	}
+
+	var w *resultWriter
+	if output != "text" {
+		var err error
+		if w, err = newResultWriter(output); err != nil {
+			return err
+		}
 	}
 
 	results := make(map[string]time.Duration)
+	verified := make(map[string]runResult)
 
 	// Measure runtime for each function
-	for _, fi := range functions {
-		var totalDuration time.Duration
-
-		for i := 0; i < n; i++ {
-			start := time.Now()
-			_, _, _ = fi.function() // Run the function
-			elapsed := time.Since(start)
-			totalDuration += elapsed
+	for _, fi := range strategies {
+		if _, err := os.Stat(fi.file); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", fi.name, err)
+			continue
 		}
+		for _, mode := range modes {
+			var totalDuration time.Duration
+			durations := make([]time.Duration, 0, n)
+			var s1, s2 float64
+			var lines int64
 
-		// Calculate and store average runtime
-		results[fi.name] = totalDuration / time.Duration(n)
+			for i := 0; i < n; i++ {
+				if mode == cacheCold {
+					if err := evictFile(fi.file); err != nil {
+						return err
+					}
+				}
+				start := time.Now()
+				s1, s2, lines = fi.function(fi.file) // Run the function
+				elapsed := time.Since(start)
+				totalDuration += elapsed
+				durations = append(durations, elapsed)
+			}
+
+			// Calculate and store average runtime
+			name := fi.name
+			if mode == cacheCold {
+				name += " (cold)"
+			}
+			results[name] = totalDuration / time.Duration(n)
+
+			r := newRunResult(fi, mode, s1, s2, lines, durations)
+			verified[name] = r
+			if w != nil {
+				if err := w.write(r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if w != nil {
+		return w.flush()
 	}
 
-	// Find the fastest and slowest functions
+	// Find the fastest and slowest functions among those whose results
+	// passed verification; a fast wrong answer does not count.
 	var fastest, slowest string
 	var fastestTime, slowestTime time.Duration
 
 	for name, avgTime := range results {
+		if verified[name].Verification != verificationOK {
+			continue
+		}
 		if fastest == "" || avgTime < fastestTime {
 			fastest = name
 			fastestTime = avgTime
@@ -70,9 +150,45 @@ func runAllFunctionsAndMeasureAvg(n int) {
 	// Print the results
 	fmt.Println("Average Runtimes:")
 	for name, avgTime := range results {
-		fmt.Printf("%s: %v\n", name, avgTime)
+		switch r := verified[name]; r.Verification {
+		case verificationFailed:
+			fmt.Printf("%s: %v (not ranked, %s)\n", name, avgTime, r.VerificationError)
+		case verificationSkipped:
+			fmt.Printf("%s: %v (not ranked, no %s)\n", name, avgTime, verifyPath(r.File))
+		case verificationApproximate:
+			fmt.Printf("%s: %v (not ranked, approximate)\n", name, avgTime)
+		default:
+			fmt.Printf("%s: %v\n", name, avgTime)
+		}
 	}
 
+	if fastest == "" {
+		fmt.Println("\nNo function passed verification")
+		return nil
+	}
 	fmt.Printf("\nFastest Function: %s with avg time %v\n", fastest, fastestTime)
 	fmt.Printf("Slowest Function: %s with avg time %v\n", slowest, slowestTime)
+	return nil
+}
+
+// measureCommand runs every strategy whose input file exists n times and
+// prints their average runtimes, ranking those whose results match
+// points-verify.txt, or a result record per strategy and cache mode as JSON
+// or CSV.
+func measureCommand(args []string) error {
+	flags := newFlagSet("measure")
+	n := flags.Int("n", 5, "runs per strategy")
+	output := flags.String("output", "text", "output format: text, json or csv")
+	cache := flags.String("cache", cacheWarm, "warm, cold to evict the input from the page cache before every run, or both")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *n < 1 {
+		return fmt.Errorf("-n must be positive")
+	}
+	if err := checkCacheMode(*cache, true); err != nil {
+		return err
+	}
+	return runAllFunctionsAndMeasureAvg(*n, *output, *cache)
 }
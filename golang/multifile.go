@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sidecarSuffixes end the names of the files the other commands write next
+// to a points file: the verification sums of the generator, the line index,
+// checkpoints, the binary format and the column exports. They are never
+// inputs unless named explicitly.
+var sidecarSuffixes = []string{"-verify.txt", "-verify.bin.txt", indexSuffix, ".ckpt", ".bin", ".col", ".cols"}
+
+func isSidecar(name string) bool {
+	for _, suffix := range sidecarSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandInputs turns the command line arguments into a sorted list of files.
+// An argument can be a file, which is taken as is, a glob such as
+// "data/*.txt" or a directory, which stands for the regular files directly in
+// it whose names match pattern. Hidden files and sidecars are left out of
+// globs and directories.
+func expandInputs(args []string, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %v", pattern, err)
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	addFile := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		isGlob := strings.ContainsAny(arg, "*?[")
+		if isGlob {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s matches no files", arg)
+			}
+		}
+
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				name := filepath.Base(path)
+				if !isGlob || !strings.HasPrefix(name, ".") && !isSidecar(name) {
+					addFile(path)
+				}
+				continue
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				name := e.Name()
+				if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || isSidecar(name) {
+					continue
+				}
+				if ok, _ := filepath.Match(pattern, name); ok {
+					addFile(filepath.Join(path, name))
+				}
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// countColumns returns the number of fields in the first non-empty line of
+// the file, or 0 for a file without one.
+func countColumns(file *os.File, f format) (int, error) {
+	buffer := make([]byte, 4096)
+	var line []byte
+	for offset := int64(0); ; {
+		n, err := file.ReadAt(buffer, offset)
+		data := buffer[:n]
+		offset += int64(n)
+		for len(data) > 0 {
+			idx := bytes.IndexByte(data, '\n')
+			if idx == -1 {
+				line = append(line, data...)
+				break
+			}
+			line = append(line, data[:idx]...)
+			if len(trimLine(line)) > 0 {
+				return bytes.Count(line, []byte{f.delimiter}) + 1, nil
+			}
+			line, data = line[:0], data[idx+1:]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(trimLine(line)) > 0 {
+		return bytes.Count(line, []byte{f.delimiter}) + 1, nil
+	}
+	return 0, nil
+}
+
+// checkColumns returns an error for the first line of data, a chunk at offset
+// in path, that has another number of fields than two. lines is how many
+// points the chunk held: when every line is one of them and the chunk has one
+// delimiter per point, no line needs to be looked at.
+func checkColumns(data []byte, offset int64, path string, f format, lines int64) error {
+	physical := int64(bytes.Count(data, []byte{'\n'}))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		physical++
+	}
+	if physical == lines && int64(bytes.Count(data, []byte{f.delimiter})) == lines {
+		return nil
+	}
+
+	lineStart := 0
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && data[i] != '\n' {
+			continue
+		}
+		line := data[lineStart:i]
+		if len(trimLine(line)) > 0 {
+			if columns := bytes.Count(line, []byte{f.delimiter}) + 1; columns != 2 {
+				return fmt.Errorf("%s: line at offset %d has %d columns separated by %q, want 2",
+					path, offset+int64(lineStart), columns, f.delimiter)
+			}
+		}
+		lineStart = i + 1
+	}
+	return nil
+}
+
+// fileSums is the result for one input file.
+type fileSums struct {
+	path       string
+	sumX, sumY float64
+	lines      int64
+}
+
+// multiReadAndSum sums every file. All line-aligned chunks of all files go
+// through one pool of workers, so a single big file or many small ones keep
+// all cores equally busy. Every line must have two columns: a file whose
+// first line does not is reported before any parsing starts, other lines
+// when their chunk is parsed.
+func multiReadAndSum(paths []string, f format) ([]fileSums, error) {
+	type job struct {
+		file  int
+		chunk chunk
+	}
+
+	files := make([]*os.File, len(paths))
+	defer func() {
+		for _, file := range files {
+			if file != nil {
+				file.Close()
+			}
+		}
+	}()
+
+	sizes := make([]int64, len(paths))
+	var totalSize int64
+	for i, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = file
+
+		columns, err := countColumns(file, f)
+		if err != nil {
+			return nil, err
+		}
+		if columns != 0 && columns != 2 {
+			return nil, fmt.Errorf("%s has %d columns separated by %q, want 2", path, columns, f.delimiter)
+		}
+
+		stat, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = stat.Size()
+		totalSize += stat.Size()
+	}
+
+	// Aim at a few chunks per worker overall, but keep them small enough that
+	// the per-worker buffers stay bounded.
+	numWorkers := runtime.NumCPU()
+	chunkSize := min(max(totalSize/int64(4*numWorkers), 1<<20), 64<<20)
+
+	var jobs []job
+	for i, file := range files {
+		n := int(max(1, (sizes[i]+chunkSize-1)/chunkSize))
+		chunks, err := splitChunks(file, 0, sizes[i], n)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			jobs = append(jobs, job{file: i, chunk: c})
+		}
+	}
+
+	queue := make(chan job, len(jobs))
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+
+	results := make([]fileSums, len(paths))
+	for i, path := range paths {
+		results[i].path = path
+	}
+	var mu sync.Mutex
+	var firstErr error
+	var firstErrJob job // The earliest failing chunk, so the error is the same every run
+	fail := func(j job, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil || j.file < firstErrJob.file || j.file == firstErrJob.file && j.chunk.offset < firstErrJob.chunk.offset {
+			firstErr, firstErrJob = err, j
+		}
+	}
+	var wg sync.WaitGroup
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buffer []byte
+			for j := range queue {
+				if int64(cap(buffer)) < j.chunk.size {
+					buffer = make([]byte, j.chunk.size)
+				}
+				data := buffer[:j.chunk.size]
+				_, err := files[j.file].ReadAt(data, j.chunk.offset)
+				if err != nil && err != io.EOF {
+					fail(j, err)
+					continue
+				}
+				x, y, lines := f.sumChunk(data)
+				if err := checkColumns(data, j.chunk.offset, paths[j.file], f, lines); err != nil {
+					fail(j, err)
+					continue
+				}
+
+				mu.Lock()
+				results[j.file].sumX += x
+				results[j.file].sumY += y
+				results[j.file].lines += lines
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// multiCommand prints the averages of each input file and of all of them
+// together. The inputs are the arguments after the flags.
+func multiCommand(args []string) error {
+	flags := newFlagSet("multi")
+	ff := addFormatFlags(flags)
+	pattern := flags.String("pattern", "*.txt", "names of the files to take from directories")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: parser multi [flags] file|glob|directory...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no input files, pass files, globs or directories after the flags")
+	}
+	f, err := ff.format()
+	if err != nil {
+		return err
+	}
+	paths, err := expandInputs(flags.Args(), *pattern)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no input files found")
+	}
+
+	results, err := multiReadAndSum(paths, f)
+	if err != nil {
+		return err
+	}
+
+	var total fileSums
+	fmt.Printf("%-40s %12s %12s %12s\n", "file", "lines", "avg x", "avg y")
+	for _, r := range results {
+		fmt.Printf("%-40s %12d %12.6f %12.6f\n", r.path, r.lines, r.sumX/float64(r.lines), r.sumY/float64(r.lines))
+		total.sumX += r.sumX
+		total.sumY += r.sumY
+		total.lines += r.lines
+	}
+	fmt.Printf("%-40s %12d %12.6f %12.6f\n", fmt.Sprintf("total (%d files)", len(results)),
+		total.lines, total.sumX/float64(total.lines), total.sumY/float64(total.lines))
+	return nil
+}
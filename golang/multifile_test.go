@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"points.txt", "other.txt", "notes.csv", ".hidden.txt",
+		"points-verify.txt", "points.txt.idx", "points.txt.ckpt",
+		"points.bin", "points.x.col", "points.y.col", "points.cols",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("1.00,2.00\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.txt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	in := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		pattern string
+		want    []string
+	}{
+		{"directory", []string{dir}, "*.txt", in("other.txt", "points.txt")},
+		{"directory with pattern", []string{dir}, "*.csv", in("notes.csv")},
+		{"directory with every name", []string{dir}, "*", in("notes.csv", "other.txt", "points.txt")},
+		{"glob", []string{filepath.Join(dir, "points*")}, "*.txt", in("points.txt")},
+		{"named sidecar", in("points-verify.txt"), "*.txt", in("points-verify.txt")},
+		{"duplicates", []string{dir, filepath.Join(dir, "points.txt")}, "*.txt", in("other.txt", "points.txt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandInputs(tt.args, tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandInputs(%q, %q) = %q, want %q", tt.args, tt.pattern, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := expandInputs([]string{dir}, "["); err == nil {
+		t.Error("a bad pattern did not fail")
+	}
+}
+
+func TestMultiReadAndSum(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt": "1.00,2.00\n3.00,4.00\n5.00,6.00",
+		"b.txt": "x,y\n10.00,-10.00\n20.50,-20.50\n",
+		"c.txt": "\n-1.25,1.25\n\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	big := filepath.Join(dir, "d.txt")
+	xs, ys := writePoints(t, big, 100000)
+	var bigX, bigY float64
+	for i := range xs {
+		bigX += xs[i]
+		bigY += ys[i]
+	}
+
+	paths, err := expandInputs([]string{dir}, "*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := multiReadAndSum(paths, formats[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileSums{
+		{filepath.Join(dir, "a.txt"), 9, 12, 3},
+		{filepath.Join(dir, "b.txt"), 30.5, -30.5, 2},
+		{filepath.Join(dir, "c.txt"), -1.25, 1.25, 1},
+		{big, bigX, bigY, 100000},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	var total fileSums
+	for i, r := range results {
+		w := want[i]
+		if r.path != w.path || r.lines != w.lines || math.Abs(r.sumX-w.sumX) > 1e-6 || math.Abs(r.sumY-w.sumY) > 1e-6 {
+			t.Errorf("result %d = %+v, want %+v", i, r, w)
+		}
+		if avg := r.sumX / float64(r.lines); math.Abs(avg-w.sumX/float64(w.lines)) > 1e-9 {
+			t.Errorf("%s: average of x %v, want %v", r.path, avg, w.sumX/float64(w.lines))
+		}
+		total.sumX += r.sumX
+		total.sumY += r.sumY
+		total.lines += r.lines
+	}
+	wantAvgX := (9 + 30.5 - 1.25 + bigX) / 100006
+	wantAvgY := (12 - 30.5 + 1.25 + bigY) / 100006
+	if total.lines != 100006 || math.Abs(total.sumX/float64(total.lines)-wantAvgX) > 1e-9 || math.Abs(total.sumY/float64(total.lines)-wantAvgY) > 1e-9 {
+		t.Errorf("combined %d lines with averages %v, %v, want 100006 with %v, %v",
+			total.lines, total.sumX/float64(total.lines), total.sumY/float64(total.lines), wantAvgX, wantAvgY)
+	}
+}
+
+func TestMultiReadAndSumColumns(t *testing.T) {
+	good := filepath.Join(t.TempDir(), "good.txt")
+	writePoints(t, good, 10)
+
+	var long strings.Builder
+	for i := 0; i < 100000; i++ {
+		long.WriteString("1.00,2.00\n")
+	}
+	badOffset := long.Len()
+	long.WriteString("1.00,2.00,3.00\n")
+	long.WriteString("4.00,5.00\n")
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"three columns", "1.00,2.00,3.00\n4.00,5.00,6.00\n", "bad.txt has 3 columns separated by ',', want 2"},
+		{"one column", "1.00\n2.00\n", "bad.txt has 1 columns separated by ',', want 2"},
+		{"later line with three columns", "1.00,2.00\n3.00,4.00,5.00\n", "bad.txt: line at offset 10 has 3 columns separated by ',', want 2"},
+		{"later line with one column", "1.00,2.00\n\n3.00\n", "bad.txt: line at offset 11 has 1 columns separated by ',', want 2"},
+		{"later chunk", long.String(), fmt.Sprintf("bad.txt: line at offset %d has 3 columns", badOffset)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := filepath.Join(t.TempDir(), "bad.txt")
+			if err := os.WriteFile(bad, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := multiReadAndSum([]string{good, bad}, formats[0])
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
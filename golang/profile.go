@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+)
+
+// profiler captures profiles of one iteration of the repetition tester. The
+// files are named <strategy>-<iteration>.cpu.pprof, .heap.pprof and .trace,
+// for go tool pprof and go tool trace, and .timeline.json for the goroutine
+// timeline in the Chrome trace format.
+type profiler struct {
+	cpu, heap, trace, timeline bool
+	dir                        string
+	iteration                  int
+}
+
+func newProfiler(kinds, dir string, iteration int) (*profiler, error) {
+	p := &profiler{dir: dir, iteration: iteration}
+	for _, kind := range strings.Split(kinds, ",") {
+		switch strings.TrimSpace(kind) {
+		case "cpu":
+			p.cpu = true
+		case "heap":
+			p.heap = true
+		case "trace":
+			p.trace = true
+		case "timeline":
+			p.timeline = true
+		default:
+			return nil, fmt.Errorf("unknown profile %q (want cpu, heap, trace or timeline)", kind)
+		}
+	}
+	if iteration < 1 {
+		return nil, fmt.Errorf("the profiled iteration must be positive")
+	}
+	return p, os.MkdirAll(dir, 0o755)
+}
+
+func (p *profiler) path(strategy, suffix string) string {
+	return filepath.Join(p.dir, fmt.Sprintf("%s-%d.%s", strategy, p.iteration, suffix))
+}
+
+// start begins the CPU profile, the trace and the timeline. The returned
+// function stops them, writes the heap profile and the timeline and returns
+// the names of the files written.
+func (p *profiler) start(strategy string) (func() ([]string, error), error) {
+	var files []string
+	var cpuFile, traceFile *os.File
+	closeAll := func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if traceFile != nil {
+			trace.Stop()
+			traceFile.Close()
+		}
+	}
+
+	if p.cpu {
+		name := p.path(strategy, "cpu.pprof")
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cpuFile = f
+		files = append(files, name)
+	}
+	if p.trace {
+		name := p.path(strategy, "trace")
+		f, err := os.Create(name)
+		if err == nil {
+			if err = trace.Start(f); err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		traceFile = f
+		files = append(files, name)
+	}
+	if p.timeline {
+		timeline = newTimelineRecorder()
+	}
+
+	return func() ([]string, error) {
+		if recorder := timeline; recorder != nil {
+			timeline = nil
+			name := p.path(strategy, "timeline.json")
+			if err := recorder.writeChromeTrace(name, strategy); err != nil {
+				return nil, err
+			}
+			files = append(files, name)
+		}
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return nil, err
+			}
+		}
+		if traceFile != nil {
+			trace.Stop()
+			if err := traceFile.Close(); err != nil {
+				return nil, err
+			}
+		}
+		if p.heap {
+			name := p.path(strategy, "heap.pprof")
+			f, err := os.Create(name)
+			if err != nil {
+				return nil, err
+			}
+			runtime.GC() // Up to date statistics, as go test -memprofile does
+			err = pprof.WriteHeapProfile(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, name)
+		}
+		return files, nil
+	}, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+)
+
+var reportedQuantiles = []float64{0.5, 0.9, 0.99}
+
+// quantilePartial is what every worker of quantilesReadAndSum sends back in
+// place of the [3]float64 of the other strategies: the sums and one sketch per
+// column.
+type quantilePartial struct {
+	sumX, sumY float64
+	lines      int64
+	x, y       *kllSketch
+}
+
+// quantilesReadAndSum estimates the quantiles of both columns without holding
+// the values in memory. Each of numWorkers workers fills its own pair of KLL
+// sketches, which are merged once all workers are done.
+func quantilesReadAndSum(filePath string, f format, k, numWorkers int) (quantilePartial, error) {
+	total := quantilePartial{}
+	var err error
+	if total.x, err = newKLLSketch(k, 1); err != nil {
+		return quantilePartial{}, err
+	}
+	total.y, _ = newKLLSketch(k, 2)
+
+	seeds := make(chan uint64, numWorkers) // A different compaction seed per worker
+	for i := 0; i < numWorkers; i++ {
+		seeds <- uint64(i + 1)
+	}
+
+	results, err := readChunksN(filePath, numWorkers, func(data []byte) quantilePartial {
+		seed := <-seeds
+		local := quantilePartial{}
+		local.x, _ = newKLLSketch(k, seed) // k was checked above
+		local.y, _ = newKLLSketch(k, ^seed)
+		forEachPoint(data, f, func(x, y float64) {
+			local.sumX += x
+			local.sumY += y
+			local.lines++
+			local.x.add(x)
+			local.y.add(y)
+		})
+		return local
+	})
+	if err != nil {
+		return quantilePartial{}, err
+	}
+
+	for _, res := range results {
+		total.sumX += res.sumX
+		total.sumY += res.sumY
+		total.lines += res.lines
+		total.x.merge(res.x)
+		total.y.merge(res.y)
+	}
+	return total, nil
+}
+
+// exactQuantiles reads every value of the file into memory and returns the
+// sorted columns. It is only meant for checking the sketches on small files.
+func exactQuantiles(filePath string, f format) ([]float64, []float64, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var xs, ys []float64
+	forEachPoint(data, f, func(x, y float64) {
+		xs = append(xs, x)
+		ys = append(ys, y)
+	})
+
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+	return xs, ys, nil
+}
+
+// exactQuantile returns the smallest value whose rank is at least q*n, the
+// same definition kllSketch.quantiles uses.
+func exactQuantile(sorted []float64, q float64) float64 {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// rankError is the distance between q and the normalized rank range of v in
+// the sorted values. Zero means v is a correct q quantile.
+func rankError(sorted []float64, v, q float64) float64 {
+	lo := float64(sort.SearchFloat64s(sorted, v)) / float64(len(sorted))
+	hi := float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > v })) / float64(len(sorted))
+	switch {
+	case q < lo:
+		return lo - q
+	case q > hi:
+		return q - hi
+	}
+	return 0
+}
+
+// quantilesCommand prints the median, p90 and p99 of both columns. With
+// -exact it also sorts all values and prints the exact quantiles and the rank
+// error of every estimate, failing when one exceeds -max-error.
+func quantilesCommand(args []string) error {
+	flags := newFlagSet("quantiles")
+	in := addInputFlags(flags, "file to parse")
+	k := flags.Int("k", 200, "sketch size; the rank error is about 1.7/k")
+	exact := flags.Bool("exact", false, "compare the estimates with exact quantiles from sorting (small files only)")
+	maxError := flags.Float64("max-error", 0.02, "largest rank error accepted by -exact")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	res, err := quantilesReadAndSum(*in.file, f, *k, runtime.NumCPU())
+	if err != nil {
+		return err
+	}
+
+	var sortedX, sortedY []float64
+	if *exact {
+		if sortedX, sortedY, err = exactQuantiles(*in.file, f); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("lines: %d\n", res.lines)
+	failed := false
+	columns := []struct {
+		name   string
+		sum    float64
+		sketch *kllSketch
+		sorted []float64
+	}{
+		{"x", res.sumX, res.x, sortedX},
+		{"y", res.sumY, res.y, sortedY},
+	}
+	for _, c := range columns {
+		fmt.Printf("%s: avg %.6f", c.name, c.sum/float64(res.lines))
+		for i, v := range c.sketch.quantiles(reportedQuantiles...) {
+			q := reportedQuantiles[i]
+			fmt.Printf(" p%g %.2f", q*100, v)
+			if *exact {
+				e := rankError(c.sorted, v, q)
+				fmt.Printf(" (exact %.2f, rank error %.4f)", exactQuantile(c.sorted, q), e)
+				failed = failed || e > *maxError
+			}
+		}
+		fmt.Println()
+	}
+
+	if failed {
+		return fmt.Errorf("rank error above %g", *maxError)
+	}
+	return nil
+}
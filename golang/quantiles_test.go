@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// TestQuantilesAccuracy compares the quantiles of the sketches, filled by four
+// workers and merged, with the exact ones the -exact flag computes. The
+// worker count is fixed so that the merge runs on any number of CPUs.
+// The rank error of every estimate must stay within 1.7/k.
+func TestQuantilesAccuracy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	if _, _, err := generateScaleFile(path, 200000, 1); err != nil {
+		t.Fatal(err)
+	}
+	sortedX, sortedY, err := exactQuantiles(path, formats[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qs := []float64{0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99}
+	for _, k := range []int{100, 200, 400} {
+		t.Run(fmt.Sprintf("k=%d", k), func(t *testing.T) {
+			res, err := quantilesReadAndSum(path, formats[0], k, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.lines != int64(len(sortedX)) || res.x.n != res.lines || res.y.n != res.lines {
+				t.Fatalf("sketches hold %d and %d of %d lines, want %d", res.x.n, res.y.n, res.lines, len(sortedX))
+			}
+
+			bound := 1.7 / float64(k)
+			for _, c := range []struct {
+				name   string
+				sketch *kllSketch
+				sorted []float64
+			}{{"x", res.x, sortedX}, {"y", res.y, sortedY}} {
+				for i, v := range c.sketch.quantiles(qs...) {
+					if e := rankError(c.sorted, v, qs[i]); e > bound {
+						t.Errorf("%s p%g = %.2f, exact %.2f, rank error %.4f above %.4f",
+							c.name, qs[i]*100, v, exactQuantile(c.sorted, qs[i]), e, bound)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKLLSketchSize(t *testing.T) {
+	for _, k := range []int{0, -1} {
+		if _, err := newKLLSketch(k, 1); err == nil {
+			t.Errorf("newKLLSketch(%d) did not fail", k)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "points.txt")
+	writePoints(t, path, 10)
+	if _, err := quantilesReadAndSum(path, formats[0], 0, 4); err == nil {
+		t.Error("quantilesReadAndSum with k 0 did not fail")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resultSchemaVersion is part of every machine-readable result. It changes
+// whenever a field is renamed, removed or changes meaning; adding a field
+// does not change it.
+const resultSchemaVersion = 1
+
+// Verification states of a runResult.
+const (
+	verificationOK          = "ok"
+	verificationFailed      = "failed"
+	verificationSkipped     = "skipped"     // No expected results, see verifyPath
+	verificationApproximate = "approximate" // An estimate, see approximateStrategies
+)
+
+type timingStats struct {
+	Runs     int   `json:"runs"`
+	MinNs    int64 `json:"min_ns"`
+	MeanNs   int64 `json:"mean_ns"`
+	MedianNs int64 `json:"median_ns"`
+	MaxNs    int64 `json:"max_ns"`
+	StddevNs int64 `json:"stddev_ns"`
+	// Every run in the order they ran, for the compare command
+	SamplesNs []int64 `json:"samples_ns"`
+}
+
+func newTimingStats(durations []time.Duration) timingStats {
+	if len(durations) == 0 {
+		return timingStats{}
+	}
+	samples := make([]int64, len(durations))
+	for i, d := range durations {
+		samples[i] = int64(d)
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var s columnStats
+	for _, d := range sorted {
+		s.add(float64(d))
+	}
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+	return timingStats{
+		Runs:      len(sorted),
+		MinNs:     int64(sorted[0]),
+		MeanNs:    int64(math.Round(s.mean)),
+		MedianNs:  int64(median),
+		MaxNs:     int64(sorted[len(sorted)-1]),
+		StddevNs:  int64(math.Round(s.stddev())),
+		SamplesNs: samples,
+	}
+}
+
+// runResult is one machine-readable result: what a strategy computed over
+// its input, whether that matches the expected results and how long it took.
+type runResult struct {
+	SchemaVersion     int         `json:"schema_version"`
+	Strategy          string      `json:"strategy"`
+	Cache             string      `json:"cache"` // warm or cold
+	File              string      `json:"file"`
+	FileBytes         int64       `json:"file_bytes"`
+	Lines             int64       `json:"lines"`
+	SumX              float64     `json:"sum_x"`
+	SumY              float64     `json:"sum_y"`
+	AvgX              float64     `json:"avg_x"`
+	AvgY              float64     `json:"avg_y"`
+	Verification      string      `json:"verification"`
+	VerificationError string      `json:"verification_error,omitempty"`
+	Timing            timingStats `json:"timing"`
+}
+
+func newRunResult(fi functionInfo, cache string, s1, s2 float64, lines int64, durations []time.Duration) runResult {
+	r := runResult{
+		SchemaVersion: resultSchemaVersion,
+		Strategy:      fi.name,
+		Cache:         cache,
+		File:          fi.file,
+		Lines:         lines,
+		SumX:          s1,
+		SumY:          s2,
+		Verification:  verificationOK,
+		Timing:        newTimingStats(durations),
+	}
+	if lines > 0 {
+		r.AvgX = s1 / float64(lines)
+		r.AvgY = s2 / float64(lines)
+	}
+	if stat, err := os.Stat(fi.file); err == nil {
+		r.FileBytes = stat.Size()
+	}
+
+	if fi.approximate() {
+		r.Verification = verificationApproximate
+	} else if err := verify(fi.file, s1, s2, lines); os.IsNotExist(err) {
+		r.Verification = verificationSkipped
+	} else if err != nil {
+		r.Verification = verificationFailed
+		r.VerificationError = err.Error()
+	}
+	return r
+}
+
+var resultCSVHeader = []string{
+	"schema_version", "strategy", "file", "file_bytes", "lines",
+	"sum_x", "sum_y", "avg_x", "avg_y", "verification", "verification_error",
+	"runs", "min_ns", "mean_ns", "median_ns", "max_ns", "stddev_ns", "samples_ns", "cache",
+}
+
+// resultWriter writes results to stdout as JSON lines, one object per line,
+// or as CSV with a header row before the first result. In CSV the samples
+// are one field of space separated numbers.
+type resultWriter struct {
+	format      string
+	json        *json.Encoder
+	csv         *csv.Writer
+	wroteHeader bool
+}
+
+func newResultWriter(format string) (*resultWriter, error) {
+	switch format {
+	case "json":
+		return &resultWriter{format: format, json: json.NewEncoder(os.Stdout)}, nil
+	case "csv":
+		return &resultWriter{format: format, csv: csv.NewWriter(os.Stdout)}, nil
+	}
+	return nil, fmt.Errorf("unknown output %q (want text, json or csv)", format)
+}
+
+func (w *resultWriter) write(r runResult) error {
+	if w.json != nil {
+		return w.json.Encode(r)
+	}
+
+	if !w.wroteHeader {
+		if err := w.csv.Write(resultCSVHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	float := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	integer := func(i int64) string { return strconv.FormatInt(i, 10) }
+	err := w.csv.Write([]string{
+		strconv.Itoa(r.SchemaVersion), r.Strategy, r.File, integer(r.FileBytes), integer(r.Lines),
+		float(r.SumX), float(r.SumY), float(r.AvgX), float(r.AvgY), r.Verification, r.VerificationError,
+		strconv.Itoa(r.Timing.Runs), integer(r.Timing.MinNs), integer(r.Timing.MeanNs),
+		integer(r.Timing.MedianNs), integer(r.Timing.MaxNs), integer(r.Timing.StddevNs),
+		strings.Trim(fmt.Sprint(r.Timing.SamplesNs), "[]"), r.Cache,
+	})
+	if err != nil {
+		return err
+	}
+	// Flush every row so that a dashboard tailing the repetition loop sees
+	// results as they come.
+	return w.flush()
+}
+
+func (w *resultWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// blockSample holds the complete lines found in one randomly placed block.
+type blockSample struct {
+	sumX, sumY float64
+	lines      int64
+	bytes      int64 // Bytes spanned by the complete lines, including '\n'
+}
+
+// sampleEstimate is what the sampling mode infers about the whole file.
+type sampleEstimate struct {
+	meanX, meanY float64
+	halfX, halfY float64 // Half width of the 95% confidence intervals
+	lines        int64   // Estimated from the file size and bytes per line
+	blocks       int
+	bytesRead    int64
+}
+
+// readBlockSample reads blockSize bytes at offset and parses the complete
+// lines in it. A block not starting at 0 skips the partial line it lands in,
+// and the partial line at its end is dropped.
+func readBlockSample(file *os.File, offset int64, blockSize int, f format) (blockSample, error) {
+	buffer := make([]byte, blockSize)
+	n, err := file.ReadAt(buffer, offset)
+	if err != nil && err != io.EOF {
+		return blockSample{}, err
+	}
+	data := buffer[:n]
+
+	if offset > 0 {
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			return blockSample{}, nil
+		}
+		data = data[idx+1:]
+	}
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+
+	var s blockSample
+	s.bytes = int64(len(data))
+	forEachPoint(data, f, func(x, y float64) {
+		s.sumX += x
+		s.sumY += y
+		s.lines++
+	})
+	return s, nil
+}
+
+// estimateMean is the ratio estimator Σsum/Σlines over the sampled blocks
+// together with the half width of its 95% confidence interval. Lines are
+// treated as clustered in blocks, so the variance comes from the spread of
+// the block sums rather than of single lines.
+func estimateMean(samples []blockSample, sum func(blockSample) float64) (float64, float64) {
+	var total float64
+	var lines int64
+	for _, s := range samples {
+		total += sum(s)
+		lines += s.lines
+	}
+	if lines == 0 {
+		return math.NaN(), math.Inf(1)
+	}
+	mean := total / float64(lines)
+
+	k := float64(len(samples))
+	if k < 2 {
+		return mean, math.Inf(1)
+	}
+	avgLines := float64(lines) / k
+	var ss float64
+	for _, s := range samples {
+		r := sum(s) - mean*float64(s.lines)
+		ss += r * r
+	}
+	variance := ss / (k * (k - 1) * avgLines * avgLines)
+	return mean, 1.96 * math.Sqrt(variance)
+}
+
+// estimateBySampling reads up to maxBlocks randomly chosen line-aligned
+// blocks with ReadAt, numWorkers at a time, and estimates the column means
+// and the number of lines from them. When tolerance is positive it stops
+// early once both confidence intervals are narrower than ±tolerance.
+func estimateBySampling(filePath string, f format, maxBlocks, blockSize int, tolerance float64, seed int64) (sampleEstimate, error) {
+	const minBlocks = 8 // Too few blocks make the interval itself unreliable
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return sampleEstimate{}, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return sampleEstimate{}, err
+	}
+	fileSize := stat.Size()
+
+	rng := rand.New(rand.NewSource(seed))
+	numWorkers := runtime.NumCPU()
+	var samples []blockSample
+	var est sampleEstimate
+
+	for len(samples) < maxBlocks {
+		round := min(numWorkers, maxBlocks-len(samples))
+		results := make([]blockSample, round)
+		errs := make([]error, round)
+		var wg sync.WaitGroup
+
+		for i := 0; i < round; i++ {
+			var offset int64
+			if fileSize > int64(blockSize) {
+				offset = rng.Int63n(fileSize - int64(blockSize) + 1)
+			}
+			wg.Add(1)
+			go func(i int, offset int64) {
+				defer wg.Done()
+				results[i], errs[i] = readBlockSample(file, offset, blockSize, f)
+			}(i, offset)
+		}
+		wg.Wait()
+
+		for i := range results {
+			if errs[i] != nil {
+				return sampleEstimate{}, errs[i]
+			}
+			samples = append(samples, results[i])
+			est.bytesRead += int64(min(int64(blockSize), fileSize))
+		}
+
+		est.meanX, est.halfX = estimateMean(samples, func(s blockSample) float64 { return s.sumX })
+		est.meanY, est.halfY = estimateMean(samples, func(s blockSample) float64 { return s.sumY })
+		if tolerance > 0 && len(samples) >= minBlocks && est.halfX <= tolerance && est.halfY <= tolerance {
+			break
+		}
+	}
+
+	var lines, spanned int64
+	for _, s := range samples {
+		lines += s.lines
+		spanned += s.bytes
+	}
+	if lines > 0 {
+		est.lines = int64(math.Round(float64(fileSize) / (float64(spanned) / float64(lines))))
+	}
+	est.blocks = len(samples)
+	return est, nil
+}
+
+// sampleCommand prints the estimated means with their 95% confidence
+// intervals and the estimated number of lines.
+func sampleCommand(args []string) error {
+	flags := newFlagSet("sample")
+	in := addInputFlags(flags, "file to parse")
+	blocks := flags.Int("blocks", 256, "maximum number of blocks to read")
+	blockSize := flags.Int("block-size", 65536, "bytes per block")
+	tolerance := flags.Float64("tolerance", 0, "stop once both 95% intervals are within ±tolerance (0 reads all blocks)")
+	seed := flags.Int64("seed", 1, "seed for choosing the blocks")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+	if *blocks < 1 || *blockSize < 1 {
+		return fmt.Errorf("-blocks and -block-size must be positive")
+	}
+
+	est, err := estimateBySampling(*in.file, f, *blocks, *blockSize, *tolerance, *seed)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(*in.file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("blocks: %d (%.2f%% of the file)\n", est.blocks, 100*float64(est.bytesRead)/float64(stat.Size()))
+	fmt.Printf("lines: ~%d\n", est.lines)
+	fmt.Printf("x: avg %.4f ± %.4f\n", est.meanX, est.halfX)
+	fmt.Printf("y: avg %.4f ± %.4f\n", est.meanY, est.halfY)
+	return nil
+}
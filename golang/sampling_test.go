@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRandomPoints writes lines of random values with two decimals and
+// returns the means of both columns.
+func writeRandomPoints(t *testing.T, path string, lines int) (meanX, meanY float64) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(42))
+	var b strings.Builder
+	var sumX, sumY int64
+	for i := 0; i < lines; i++ {
+		x, y := rng.Int63n(20001)-10000, rng.Int63n(5001)+1000
+		fmt.Fprintf(&b, "%.2f,%.2f\n", float64(x)/100, float64(y)/100)
+		sumX += x
+		sumY += y
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return float64(sumX) / 100 / float64(lines), float64(sumY) / 100 / float64(lines)
+}
+
+func TestEstimateBySamplingCoversMean(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	const lines = 200000
+	meanX, meanY := writeRandomPoints(t, path, lines)
+
+	const seeds = 40
+	var coveredX, coveredY int
+	for seed := int64(1); seed <= seeds; seed++ {
+		est, err := estimateBySampling(path, formats[0], 32, 4096, 0, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if est.blocks != 32 {
+			t.Fatalf("seed %d: read %d blocks, want all 32 without a tolerance", seed, est.blocks)
+		}
+		if math.Abs(est.meanX-meanX) <= est.halfX {
+			coveredX++
+		}
+		if math.Abs(est.meanY-meanY) <= est.halfY {
+			coveredY++
+		}
+		if math.Abs(float64(est.lines-lines)) > 0.01*lines {
+			t.Errorf("seed %d: estimated %d lines, want about %d", seed, est.lines, lines)
+		}
+	}
+	// A 95% interval misses about 2 times in 40; 6 misses or more is
+	// unlikely for an interval of the right width.
+	if coveredX < seeds-5 || coveredY < seeds-5 {
+		t.Errorf("the intervals covered the mean of x %d and of y %d times out of %d", coveredX, coveredY, seeds)
+	}
+}
+
+func TestEstimateBySamplingTolerance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	meanX, meanY := writeRandomPoints(t, path, 200000)
+
+	est, err := estimateBySampling(path, formats[0], 256, 4096, 5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.blocks < 8 || est.blocks >= 256 {
+		t.Errorf("a wide tolerance read %d blocks, want it to stop early after at least 8", est.blocks)
+	}
+	if est.halfX > 5 || est.halfY > 5 {
+		t.Errorf("stopped with intervals ±%v and ±%v, wider than the tolerance", est.halfX, est.halfY)
+	}
+	if math.Abs(est.meanX-meanX) > 5 || math.Abs(est.meanY-meanY) > 5 {
+		t.Errorf("estimated means %v, %v, want %v, %v within 5", est.meanX, est.meanY, meanX, meanY)
+	}
+
+	est, err = estimateBySampling(path, formats[0], 64, 4096, 1e-9, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.blocks != 64 {
+		t.Errorf("a tolerance that is never met read %d blocks, want all 64", est.blocks)
+	}
+}
+
+func TestSampledStrategyIsApproximate(t *testing.T) {
+	fi, err := findStrategy("sampledParsingWithReadAt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.approximate() {
+		t.Fatal("sampledParsingWithReadAt is not marked approximate")
+	}
+	if r := newRunResult(fi, cacheWarm, 1, 2, 3, nil); r.Verification != verificationApproximate {
+		t.Errorf("verification of an approximate strategy = %q, want %q", r.Verification, verificationApproximate)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scaleEfficiency is the parallel efficiency below which a strategy is
+// considered to stop scaling, contention or imbalance eating the extra
+// processors.
+const scaleEfficiency = 0.8
+
+// scalePoint is the best time of one strategy on one file size at one
+// GOMAXPROCS. The speedup is over GOMAXPROCS=1, or, when the first value run
+// is higher, over that value times its GOMAXPROCS.
+type scalePoint struct {
+	strategy     string
+	lines        int
+	fileBytes    int64
+	procs        int
+	best         time.Duration
+	speedup      float64
+	verification string
+}
+
+func (p scalePoint) throughput() float64 {
+	return float64(p.fileBytes) / p.best.Seconds() / 1e6
+}
+
+// efficiency is the speedup divided by GOMAXPROCS, 1 for perfect scaling.
+func (p scalePoint) efficiency() float64 {
+	return p.speedup / float64(p.procs)
+}
+
+// generateScaleFile writes lines random points to path the way the generator
+// does, and returns the sums of the values as written.
+func generateScaleFile(path string, lines int, seed int64) (float64, float64, error) {
+	const lo, hi = -99.99, 99.99
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	rng := rand.New(rand.NewSource(seed))
+	w := bufio.NewWriter(file)
+	var sumX, sumY int64 // Hundredths, so that the sums are exact
+	var line []byte
+	for i := 0; i < lines; i++ {
+		r1 := lo + rng.Float64()*(hi-lo)
+		r2 := r1 + rng.Float64()*(hi-r1)
+		x, y := int64(math.Round(r1*100)), int64(math.Round(r2*100))
+		sumX += x
+		sumY += y
+		line = strconv.AppendFloat(line[:0], float64(x)/100, 'f', 2, 64)
+		line = append(line, ',')
+		line = strconv.AppendFloat(line, float64(y)/100, 'f', 2, 64)
+		line = append(line, '\n')
+		w.Write(line)
+	}
+	if err := w.Flush(); err != nil {
+		return 0, 0, err
+	}
+	return float64(sumX) / 100, float64(sumY) / 100, file.Close()
+}
+
+// scaleProcs returns 1, 2, 4, ... up to numCPU, ending with numCPU itself.
+func scaleProcs(numCPU int) []int {
+	var procs []int
+	for p := 1; p < numCPU; p *= 2 {
+		procs = append(procs, p)
+	}
+	return append(procs, numCPU)
+}
+
+func parseIntList(list, name string) ([]int, error) {
+	var values []int
+	for _, s := range strings.Split(list, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("invalid %s %q", name, s)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// printScaleChart draws the throughput of every strategy at every GOMAXPROCS
+// of one file size, scaled to the best of them, and the parallel efficiency,
+// the speedup over GOMAXPROCS=1 divided by GOMAXPROCS.
+func printScaleChart(points []scalePoint, selected []functionInfo) {
+	const throughputWidth = 40
+	const efficiencyWidth = 20
+
+	var most float64
+	for _, p := range points {
+		most = math.Max(most, p.throughput())
+	}
+
+	fmt.Printf("%d lines, %.1f MB\n", points[0].lines, float64(points[0].fileBytes)/1e6)
+	for _, fi := range selected {
+		fmt.Printf("%s\n", fi.name)
+		scalesTo, stopped := 0, false
+		for _, p := range points {
+			if p.strategy != fi.name {
+				continue
+			}
+			efficiency := p.efficiency()
+			if !stopped && efficiency >= scaleEfficiency {
+				scalesTo = p.procs
+			} else {
+				stopped = true
+			}
+			bar := int(p.throughput() * throughputWidth / most)
+			effBar := min(int(efficiency*efficiencyWidth), efficiencyWidth)
+			note := ""
+			if p.verification == verificationFailed {
+				note = " result does not match"
+			}
+			fmt.Printf("  %5d %9.1f MB/s | %-*s | %4.0f%% %-*s|%s\n", p.procs, p.throughput(),
+				throughputWidth, strings.Repeat("#", bar), 100*efficiency, efficiencyWidth, strings.Repeat("#", effBar), note)
+		}
+		if stopped {
+			fmt.Printf("  efficiency stays at or above %.0f%% up to GOMAXPROCS=%d\n", 100*scaleEfficiency, scalesTo)
+		} else {
+			fmt.Printf("  efficiency stays at or above %.0f%% throughout\n", 100*scaleEfficiency)
+		}
+	}
+	fmt.Println()
+}
+
+// scaleCommand runs strategies across GOMAXPROCS values and generated file
+// sizes, writes the best time of each combination to a CSV file and charts
+// throughput and parallel efficiency. Many strategies start a fixed number
+// of goroutines, or one per CPU, whatever GOMAXPROCS is, so the chart shows
+// how well they use the processors they are given.
+func scaleCommand(args []string) error {
+	flags := newFlagSet("scale")
+	only := flags.String("strategies", "", "comma separated strategies to run (default all that can read any text file)")
+	sizes := flags.String("sizes", "1000000,10000000", "comma separated numbers of lines of the generated files")
+	procsList := flags.String("procs", "", "comma separated GOMAXPROCS values (default 1, 2, 4, ... up to the number of CPUs)")
+	n := flags.Int("n", 3, "runs per combination, the best one counts")
+	dir := flags.String("dir", "", "directory for the generated files, which are kept (default a temporary directory)")
+	csvPath := flags.String("csv", "scale.csv", "CSV file for the results")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *n < 1 {
+		return fmt.Errorf("-n must be positive")
+	}
+	lineCounts, err := parseIntList(*sizes, "size")
+	if err != nil {
+		return err
+	}
+	procs := scaleProcs(runtime.NumCPU())
+	if *procsList != "" {
+		if procs, err = parseIntList(*procsList, "GOMAXPROCS"); err != nil {
+			return err
+		}
+	}
+
+	// parse and the binary strategy always read the same file, so they cannot
+	// run on the generated ones.
+	var selected []functionInfo
+	if *only == "" {
+		for _, fi := range strategies {
+			if fi.file == "points.txt" {
+				selected = append(selected, fi)
+			}
+		}
+	} else {
+		for _, name := range strings.Split(*only, ",") {
+			fi, err := findStrategy(strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			if fi.name == parseStrategy.name || fi.file != "points.txt" {
+				return fmt.Errorf("%s cannot read the generated files", fi.name)
+			}
+			selected = append(selected, fi)
+		}
+	}
+
+	if *dir == "" {
+		if *dir, err = os.MkdirTemp("", "scale"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(*dir)
+	} else if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(*csvPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := csv.NewWriter(out)
+	w.Write([]string{"strategy", "lines", "file_bytes", "gomaxprocs", "best_ns", "mb_per_s", "speedup", "efficiency", "verification"})
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, lines := range lineCounts {
+		path := filepath.Join(*dir, fmt.Sprintf("points-%d.txt", lines))
+		fmt.Fprintf(os.Stderr, "Generating %s\n", path)
+		wantX, wantY, err := generateScaleFile(path, lines, int64(lines))
+		if err != nil {
+			return err
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		var points []scalePoint
+		for _, fi := range selected {
+			var base time.Duration
+			for _, p := range procs {
+				runtime.GOMAXPROCS(p)
+				var sumX, sumY float64
+				var count int64
+				best, _ := bestOf(*n, func() error {
+					sumX, sumY, count = fi.function(path)
+					return nil
+				})
+				if base == 0 {
+					base = time.Duration(p) * best
+				}
+				point := scalePoint{fi.name, lines, stat.Size(), p, best, float64(base) / float64(best), verificationOK}
+				if fi.approximate() {
+					point.verification = verificationApproximate
+				} else if count != int64(lines) || math.Abs(sumX-wantX) > 0.01 || math.Abs(sumY-wantY) > 0.01 {
+					point.verification = verificationFailed
+				}
+				points = append(points, point)
+				w.Write([]string{
+					fi.name, strconv.Itoa(lines), strconv.FormatInt(stat.Size(), 10), strconv.Itoa(p),
+					strconv.FormatInt(best.Nanoseconds(), 10), strconv.FormatFloat(point.throughput(), 'f', 2, 64),
+					strconv.FormatFloat(point.speedup, 'f', 3, 64), strconv.FormatFloat(point.efficiency(), 'f', 3, 64),
+					point.verification,
+				})
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return err
+			}
+		}
+		printScaleChart(points, selected)
+	}
+	return out.Close()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// columnStats holds the descriptive statistics of one column. Values are
+// added one at a time with Welford's algorithm, and the partial statistics of
+// different workers are combined with the parallel variant of it (Chan et
+// al.), so the result does not depend on how the file was split or on the
+// order in which workers finish.
+type columnStats struct {
+	count int64
+	sum   float64
+	mean  float64
+	m2    float64 // Sum of squared differences from the mean
+	min   float64
+	max   float64
+}
+
+func (s *columnStats) add(v float64) {
+	s.count++
+	s.sum += v
+	delta := v - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (v - s.mean)
+
+	if s.count == 1 || v < s.min {
+		s.min = v
+	}
+	if s.count == 1 || v > s.max {
+		s.max = v
+	}
+}
+
+func (s *columnStats) merge(o columnStats) {
+	if o.count == 0 {
+		return
+	}
+	if s.count == 0 {
+		*s = o
+		return
+	}
+
+	n := s.count + o.count
+	delta := o.mean - s.mean
+	s.m2 += o.m2 + delta*delta*float64(s.count)*float64(o.count)/float64(n)
+	s.mean += delta * float64(o.count) / float64(n)
+	s.sum += o.sum
+	s.count = n
+	s.min = math.Min(s.min, o.min)
+	s.max = math.Max(s.max, o.max)
+}
+
+// variance is the population variance of the column.
+func (s columnStats) variance() float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	return s.m2 / float64(s.count)
+}
+
+func (s columnStats) stddev() float64 {
+	return math.Sqrt(s.variance())
+}
+
+// pointStats holds the statistics of both columns of a points file.
+type pointStats struct {
+	x, y columnStats
+}
+
+func (p *pointStats) add(x, y float64) {
+	p.x.add(x)
+	p.y.add(y)
+}
+
+func (p *pointStats) merge(o pointStats) {
+	p.x.merge(o.x)
+	p.y.merge(o.y)
+}
+
+// statsReadAndSum computes the sums together with min, max, variance and
+// standard deviation of both columns in a single pass. Every worker fills
+// its own pointStats, which are merged once all of them are done.
+func statsReadAndSum(filePath string, f format) (pointStats, error) {
+	results, err := readChunks(filePath, func(data []byte) pointStats {
+		var local pointStats
+		forEachPoint(data, f, local.add)
+		return local
+	})
+	if err != nil {
+		return pointStats{}, err
+	}
+
+	var total pointStats
+	for _, res := range results {
+		total.merge(res)
+	}
+	return total, nil
+}
+
+func printColumnStats(name string, s columnStats) {
+	fmt.Printf("%s: sum %.2f avg %.6f min %.2f max %.2f variance %.6f stddev %.6f\n",
+		name, s.sum, s.mean, s.min, s.max, s.variance(), s.stddev())
+}
+
+// statsCommand prints the descriptive statistics of both columns.
+func statsCommand(args []string) error {
+	flags := newFlagSet("stats")
+	in := addInputFlags(flags, "file to parse")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := in.format()
+	if err != nil {
+		return err
+	}
+
+	res, err := statsReadAndSum(*in.file, f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("lines: %d\n", res.x.count)
+	printColumnStats("x", res.x)
+	printColumnStats("y", res.y)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// exactStats computes the statistics of the values in two passes.
+func exactStats(values []float64) columnStats {
+	s := columnStats{count: int64(len(values)), min: math.Inf(1), max: math.Inf(-1)}
+	for _, v := range values {
+		s.sum += v
+		s.min = math.Min(s.min, v)
+		s.max = math.Max(s.max, v)
+	}
+	s.mean = s.sum / float64(s.count)
+	for _, v := range values {
+		s.m2 += (v - s.mean) * (v - s.mean)
+	}
+	return s
+}
+
+func checkColumnStats(t *testing.T, name string, got, want columnStats) {
+	t.Helper()
+	if got.count != want.count || got.min != want.min || got.max != want.max {
+		t.Errorf("%s: count %d min %v max %v, want %d %v %v", name, got.count, got.min, got.max, want.count, want.min, want.max)
+	}
+	if math.Abs(got.sum-want.sum) > 1e-6 || math.Abs(got.mean-want.mean) > 1e-9 ||
+		math.Abs(got.variance()-want.variance()) > 1e-9*want.variance() {
+		t.Errorf("%s: sum %v mean %v variance %v, want %v %v %v", name, got.sum, got.mean, got.variance(), want.sum, want.mean, want.variance())
+	}
+}
+
+func TestStatsReadAndSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "points.txt")
+	xs, ys := writePoints(t, path, 50000)
+	wantX, wantY := exactStats(xs), exactStats(ys)
+
+	res, err := statsReadAndSum(path, formats[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkColumnStats(t, "stats x", res.x, wantX)
+	checkColumnStats(t, "stats y", res.y, wantY)
+}
+
+func TestColumnStatsMerge(t *testing.T) {
+	values := []float64{3.5, -1.25, 99.99, 0, -99.99, 42, 7.5, 7.5}
+	want := exactStats(values)
+	for split := 0; split <= len(values); split++ {
+		var a, b columnStats
+		for _, v := range values[:split] {
+			a.add(v)
+		}
+		for _, v := range values[split:] {
+			b.add(v)
+		}
+		a.merge(b)
+		checkColumnStats(t, "merged", a, want)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Phases of the goroutines of the concurrent strategies.
+const (
+	phaseOpen  = "open"
+	phaseRead  = "read"
+	phaseParse = "parse"
+	phaseSend  = "send" // Sending lines, chunks or results to another goroutine
+	phaseWait  = "wait" // Waiting to receive from a channel or for other goroutines
+)
+
+// timelineMinSpan is the shortest phase kept as a span of its own. The
+// strategies that send every line through a channel change phases millions
+// of times; shorter phases only count in the totals of their goroutine.
+const timelineMinSpan = 10 * time.Microsecond
+
+// timeline records the phases of the goroutines of the strategy being run.
+// It is nil unless a timeline was asked for, and the instrumentation then
+// costs a nil check per phase change.
+var timeline *timelineRecorder
+
+type timelineRecorder struct {
+	start      time.Time
+	mu         sync.Mutex
+	goroutines []*goroutineTimeline
+}
+
+type timelineSpan struct {
+	phase      string
+	start, end time.Duration // Since the start of the recording
+}
+
+// goroutineTimeline holds the phases of one goroutine. Only that goroutine
+// writes to it, so recording a phase takes no lock.
+type goroutineTimeline struct {
+	recorder   *timelineRecorder
+	role       string
+	phase      string
+	phaseStart time.Duration
+	begin, end time.Duration
+	spans      []timelineSpan
+	totals     map[string]time.Duration
+}
+
+func newTimelineRecorder() *timelineRecorder {
+	return &timelineRecorder{start: time.Now()}
+}
+
+// track starts the timeline of the calling goroutine in the given phase. It
+// returns nil, on which all methods do nothing, unless a timeline is being
+// recorded.
+func track(role, phase string) *goroutineTimeline {
+	r := timeline
+	if r == nil {
+		return nil
+	}
+	now := time.Since(r.start)
+	g := &goroutineTimeline{
+		recorder:   r,
+		role:       role,
+		phase:      phase,
+		phaseStart: now,
+		begin:      now,
+		totals:     make(map[string]time.Duration),
+	}
+	r.mu.Lock()
+	r.goroutines = append(r.goroutines, g)
+	r.mu.Unlock()
+	return g
+}
+
+// enter ends the current phase of the goroutine and starts the next one.
+// It is small enough to be inlined, so that the strategies only pay for the
+// nil check when no timeline is recorded.
+func (g *goroutineTimeline) enter(phase string) {
+	if g != nil {
+		g.switchPhase(phase)
+	}
+}
+
+func (g *goroutineTimeline) switchPhase(phase string) {
+	if phase == g.phase {
+		return
+	}
+	now := time.Since(g.recorder.start)
+	g.endPhase(now)
+	g.phase, g.phaseStart = phase, now
+}
+
+// done ends the last phase. It must be called before the goroutine signals
+// that it has finished, as the timeline is written once the strategy returns.
+func (g *goroutineTimeline) done() {
+	if g == nil {
+		return
+	}
+	g.end = time.Since(g.recorder.start)
+	g.endPhase(g.end)
+}
+
+func (g *goroutineTimeline) endPhase(now time.Duration) {
+	elapsed := now - g.phaseStart
+	g.totals[g.phase] += elapsed
+	if elapsed >= timelineMinSpan {
+		g.spans = append(g.spans, timelineSpan{g.phase, g.phaseStart, now})
+	}
+}
+
+// traceEvent is an event of the Chrome trace event format, with times in
+// microseconds.
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Phase string         `json:"ph"`
+	Ts    float64        `json:"ts"`
+	Dur   float64        `json:"dur,omitempty"`
+	Pid   int            `json:"pid"`
+	Tid   int            `json:"tid"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// writeChromeTrace writes the timeline as a Chrome trace, for
+// chrome://tracing or ui.perfetto.dev. Every goroutine is a thread with one
+// span over its whole life, whose arguments are the milliseconds it spent
+// in every phase, and the spans of its phases nested in it.
+func (r *timelineRecorder) writeChromeTrace(path, strategy string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []traceEvent{{Name: "process_name", Phase: "M", Pid: 1, Args: map[string]any{"name": strategy}}}
+	roles := make(map[string]int)
+	for _, g := range r.goroutines {
+		roles[g.role]++
+	}
+	numbers := make(map[string]int)
+	for i, g := range r.goroutines {
+		tid := i + 1
+		name := g.role
+		if roles[g.role] > 1 {
+			numbers[g.role]++
+			name = fmt.Sprintf("%s %d", g.role, numbers[g.role])
+		}
+		end := g.end
+		if end == 0 {
+			end = g.phaseStart // The goroutine gave up without calling done
+		}
+
+		totals := make(map[string]any, len(g.totals))
+		for phase, d := range g.totals {
+			totals[phase+" ms"] = float64(d) / float64(time.Millisecond)
+		}
+		events = append(events,
+			traceEvent{Name: "thread_name", Phase: "M", Pid: 1, Tid: tid, Args: map[string]any{"name": name}},
+			traceEvent{Name: "thread_sort_index", Phase: "M", Pid: 1, Tid: tid, Args: map[string]any{"sort_index": tid}},
+			traceEvent{Name: g.role, Phase: "X", Ts: microseconds(g.begin), Dur: microseconds(end - g.begin), Pid: 1, Tid: tid, Args: totals},
+		)
+		for _, s := range g.spans {
+			events = append(events, traceEvent{Name: s.phase, Phase: "X", Ts: microseconds(s.start), Dur: microseconds(s.end - s.start), Pid: 1, Tid: tid})
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(file).Encode(map[string]any{"traceEvents": events, "displayTimeUnit": "ms"})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}